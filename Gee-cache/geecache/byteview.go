@@ -1,8 +1,12 @@
 package geecache
 
+import "time"
+
 // A ByteView holds an immutable view of bytes.
 type ByteView struct {
 	b []byte
+	// expire is the time the value is dropped from the cache, zero never expires
+	expire time.Time
 }
 
 // Len returns the view's length
@@ -10,6 +14,11 @@ func (v ByteView) Len() int {
 	return len(v.b)
 }
 
+// Expired reports whether the value is past its expiry time
+func (v ByteView) Expired() bool {
+	return !v.expire.IsZero() && time.Now().After(v.expire)
+}
+
 // ByteSlice  returns a copy of the data as a byte slice
 func (v ByteView) ByteSlice() []byte {
 	return cloneBytes(v.b)
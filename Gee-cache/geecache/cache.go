@@ -27,6 +27,11 @@ func (c *cache) get(key string) (view ByteView, ok bool) {
 		return
 	}
 	if v, ok := c.lru.Get(key); ok {
+		// 过期的值直接删除，当作未命中
+		if v.(ByteView).Expired() {
+			c.lru.Remove(key)
+			return ByteView{}, false
+		}
 		return v.(ByteView), ok
 	}
 	return
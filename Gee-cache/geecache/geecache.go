@@ -1,11 +1,13 @@
 package geecache
 
 import (
+	"context"
 	"fmt"
 	pb "geecache/geecachepb"
 	"geecache/singleflight"
 	"log"
 	"sync"
+	"time"
 )
 
 type Getter interface {
@@ -18,6 +20,25 @@ func (f GetterFunc) Get(key string) ([]byte, error) {
 	return f(key)
 }
 
+// ExpiringGetter is a Getter whose values expire, Group uses GetExpiring
+// instead of Get to load them; the zero time never expires
+type ExpiringGetter interface {
+	Getter
+	GetExpiring(key string) ([]byte, time.Time, error)
+}
+
+// ExpiringGetterFunc implements ExpiringGetter with a function
+type ExpiringGetterFunc func(key string) ([]byte, time.Time, error)
+
+func (f ExpiringGetterFunc) Get(key string) ([]byte, error) {
+	b, _, err := f(key)
+	return b, err
+}
+
+func (f ExpiringGetterFunc) GetExpiring(key string) ([]byte, time.Time, error) {
+	return f(key)
+}
+
 // A Group is a cache namespace and associated data loaded spread over
 type Group struct {
 	name      string
@@ -69,6 +90,12 @@ func GetGroup(name string) *Group {
 }
 
 func (g *Group) Get(key string) (ByteView, error) {
+	return g.GetContext(context.Background(), key)
+}
+
+// GetContext get the value of key, ctx is passed to the peer owning the key,
+// eg. to cancel the request or propagate the trace of the caller
+func (g *Group) GetContext(ctx context.Context, key string) (ByteView, error) {
 	if key == "" {
 		return ByteView{}, fmt.Errorf("key is nil")
 	}
@@ -79,18 +106,18 @@ func (g *Group) Get(key string) (ByteView, error) {
 	}
 
 	//缓存未命中从回掉函数查询
-	return g.load(key)
+	return g.load(ctx, key)
 
 }
 
-func (g *Group) load(key string) (value ByteView, err error) {
+func (g *Group) load(ctx context.Context, key string) (value ByteView, err error) {
 
 	//使用single flight Do包裹起来，确保大量相同的key只请求一次
 	view, err := g.loader.Do(key, func() (interface{}, error) {
 		if g.peer != nil {
 			if peer, ok := g.peer.PickPeer(key); ok {
 				// 若peer为远程节点，则从远程peer获取
-				if value, err = g.getFromPeer(peer, key); err == nil {
+				if value, err = g.getFromPeer(ctx, peer, key); err == nil {
 					return value, nil
 				}
 				log.Println("[GeeCache] Failed to get from peer", err)
@@ -106,14 +133,19 @@ func (g *Group) load(key string) (value ByteView, err error) {
 	return
 }
 
-func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
+func (g *Group) getFromPeer(ctx context.Context, peer PeerGetter, key string) (ByteView, error) {
 	// 从远程节点获取
 	req := &pb.Request{
 		Group: g.name,
 		Key:   key,
 	}
 	res := &pb.Response{}
-	err := peer.Get(req, res)
+	var err error
+	if cp, ok := peer.(ContextPeerGetter); ok {
+		err = cp.GetContext(ctx, req, res)
+	} else {
+		err = peer.Get(req, res)
+	}
 	if err != nil {
 		return ByteView{}, err
 	}
@@ -121,18 +153,32 @@ func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 }
 
 func (g *Group) loadLocally(key string) (ByteView, error) {
-	bytes, err := g.getter.Get(key)
+	var bytes []byte
+	var expire time.Time
+	var err error
+	if getter, ok := g.getter.(ExpiringGetter); ok {
+		bytes, expire, err = getter.GetExpiring(key)
+	} else {
+		bytes, err = g.getter.Get(key)
+	}
 	if err != nil {
 		return ByteView{}, err
 	}
 	value := ByteView{
-		b: cloneBytes(bytes),
+		b:      cloneBytes(bytes),
+		expire: expire,
 	}
 	// 更新缓存
 	g.populateCache(key, value)
 	return value, nil
 }
 
+// Set store value for key in the cache of this instance until expire, the zero
+// time never expires; the peers get it from this instance when it owns key
+func (g *Group) Set(key string, value []byte, expire time.Time) {
+	g.populateCache(key, ByteView{b: cloneBytes(value), expire: expire})
+}
+
 func (g *Group) populateCache(key string, value ByteView) {
 	g.mainCache.add(key, value)
 }
@@ -1,9 +1,14 @@
 package geecache
 
 import (
+	"context"
 	"fmt"
+	pb "geecache/geecachepb"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 var db = map[string]string{
@@ -41,3 +46,120 @@ func TestGet(t *testing.T) {
 		}
 	}
 }
+
+func TestExpiringGetter(t *testing.T) {
+	loads := 0
+	expire := time.Now().Add(time.Hour)
+	group := NewGroup("expiring", 2<<10, ExpiringGetterFunc(func(key string) ([]byte, time.Time, error) {
+		loads++
+		return []byte(key), expire, nil
+	}))
+	if view, err := group.Get("Tom"); err != nil || view.String() != "Tom" {
+		t.Fatalf("Get(Tom) = %q, %v", view, err)
+	}
+	group.Get("Tom")
+	if loads != 1 {
+		t.Fatalf("loads = %d before expiry, want 1", loads)
+	}
+	// 过期后重新加载
+	expire = time.Now().Add(-time.Second)
+	group.Get("Jack")
+	group.Get("Jack")
+	if loads != 3 {
+		t.Fatalf("loads = %d after expiry, want 3", loads)
+	}
+}
+
+type traceKey struct{}
+
+// roundTripperFunc adapts a func to http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestHTTPPoolContext(t *testing.T) {
+	NewGroup("traced", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return []byte(key), nil
+	}))
+	owner := NewHTTPPool("owner")
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("traceparent")
+		owner.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	pool := NewHTTPPool("self")
+	// 从请求的 context 取出 trace 写入请求头
+	pool.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if v, ok := req.Context().Value(traceKey{}).(string); ok {
+			req = req.Clone(req.Context())
+			req.Header.Set("traceparent", v)
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	pool.Set(srv.URL)
+	picked, ok := pool.PickPeer("Tom")
+	if !ok {
+		t.Fatal("no peer picked")
+	}
+	peer, ok := picked.(ContextPeerGetter)
+	if !ok {
+		t.Fatal("the http peer doesn't take a context")
+	}
+	traceparent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	ctx := context.WithValue(context.Background(), traceKey{}, traceparent)
+	out := &pb.Response{}
+	if err := peer.GetContext(ctx, &pb.Request{Group: "traced", Key: "Tom"}, out); err != nil {
+		t.Fatal(err)
+	}
+	if string(out.Value) != "Tom" || received != traceparent {
+		t.Errorf("value %q, traceparent %q", out.Value, received)
+	}
+
+	// 取消的 context 不再请求节点
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	received = ""
+	if err := peer.GetContext(ctx, &pb.Request{Group: "traced", Key: "Sam"}, out); err == nil || received != "" {
+		t.Errorf("canceled Get = %v, traceparent %q", err, received)
+	}
+}
+
+// plainPeer only implements PeerGetter, without a context
+type plainPeer struct{}
+
+func (p plainPeer) PickPeer(key string) (PeerGetter, bool) {
+	return p, true
+}
+
+func (p plainPeer) Get(in *pb.Request, out *pb.Response) error {
+	out.Value = []byte("peer " + in.Key)
+	return nil
+}
+
+func TestPlainPeerGetter(t *testing.T) {
+	group := NewGroup("plain", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s loaded locally", key)
+	}))
+	group.RegisterPeers(plainPeer{})
+	if view, err := group.GetContext(context.Background(), "Tom"); err != nil || view.String() != "peer Tom" {
+		t.Errorf("GetContext(Tom) = %q, %v", view, err)
+	}
+}
+
+func TestGroupSet(t *testing.T) {
+	group := NewGroup("set", 2<<10, GetterFunc(func(key string) ([]byte, error) {
+		return nil, fmt.Errorf("%s not set", key)
+	}))
+	group.Set("Tom", []byte("630"), time.Now().Add(time.Hour))
+	group.Set("Jack", []byte("589"), time.Now().Add(-time.Second))
+	if view, err := group.Get("Tom"); err != nil || view.String() != "630" {
+		t.Errorf("Get(Tom) = %q, %v", view, err)
+	}
+	if _, err := group.Get("Jack"); err == nil {
+		t.Error("Get(Jack) returned an expired value")
+	}
+}
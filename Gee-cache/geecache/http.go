@@ -1,6 +1,7 @@
 package geecache
 
 import (
+	"context"
 	"fmt"
 	"geecache/consistenthash"
 	pb "geecache/geecachepb"
@@ -28,6 +29,10 @@ type HTTPPool struct {
 	// 新增成员变量 httpGetters，映射远程节点与对应的 httpGetter。
 	// 每一个远程节点对应一个 httpGetter，因为 httpGetter 与远程节点的地址 baseURL 有关。
 	httpGetters map[string]*httpGetter
+	// Transport sends the requests to the peers, default http.DefaultTransport.
+	// A transport injecting the trace headers of the request context,
+	// eg. gee.TraceTransport, propagates the trace between the peers
+	Transport http.RoundTripper
 }
 
 // NewHTTPPool initializes an HTTP pool of peers.
@@ -64,7 +69,11 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	//从节点中找key
-	view, err := group.Get(key)
+	view, err := group.GetContext(r.Context(), key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	// Write the value to the response body as a proto message.
 	body, err := proto.Marshal(&pb.Response{Value: view.ByteSlice()})
 	if err != nil {
@@ -90,6 +99,7 @@ func (p *HTTPPool) Set(peers ...string) {
 			// 给每个节点创建一个httpGetter ，设置路径
 			// peer + defaultBasePath: 127.0.0.1:8081/_geecache/
 			baseURL: peer + p.basePath,
+			pool:    p,
 		}
 	}
 }
@@ -109,16 +119,27 @@ func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 
 type httpGetter struct {
 	baseURL string
+	pool    *HTTPPool
 }
 
 func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
+	return h.GetContext(context.Background(), in, out)
+}
+
+// GetContext is Get with the request bound to ctx
+func (h *httpGetter) GetContext(ctx context.Context, in *pb.Request, out *pb.Response) error {
 	u := fmt.Sprintf(
 		"%v%v/%v",
 		h.baseURL,
 		url.QueryEscape(in.Group),
 		url.QueryEscape(in.Key),
 	)
-	res, err := http.Get(u)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: h.pool.Transport}
+	res, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -135,4 +156,4 @@ func (h *httpGetter) Get(in *pb.Request, out *pb.Response) error {
 	return nil
 }
 
-var _ PeerGetter = (*httpGetter)(nil)
+var _ ContextPeerGetter = (*httpGetter)(nil)
@@ -61,6 +61,19 @@ func (c *Cache) RemoveOldest() {
 	}
 }
 
+// Remove remove the key from cache
+func (c *Cache) Remove(key string) {
+	if elem, ok := c.cache[key]; ok {
+		c.ll.Remove(elem)
+		kv := elem.Value.(*entry)
+		delete(c.cache, kv.key)
+		c.nowBytes -= int64(len(kv.key)) + int64(kv.value.Len())
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+}
+
 // Add to add a element to cache
 func (c *Cache) Add(key string, value Value) {
 	//是否存在
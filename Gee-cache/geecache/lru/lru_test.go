@@ -63,3 +63,14 @@ func TestOnEvicted(t *testing.T) {
 		t.Fatalf("Call OnEvicted failed, expect keys equals to %s", expect)
 	}
 }
+
+func TestCache_Remove(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	lru.Add("key2", String("5678"))
+	lru.Remove("key1")
+	lru.Remove("key3")
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 1 || lru.nowBytes != int64(len("key2")+len("5678")) {
+		t.Fatal("lru Remove key1 failed")
+	}
+}
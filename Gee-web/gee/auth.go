@@ -0,0 +1,156 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// principalKey is the Context key of the authenticated principal
+const principalKey = "gee/principal"
+
+// Principal is the identity set by the authentication middlewares
+type Principal struct {
+	// Subject is the user name, the name bound to an api key or the sub claim
+	Subject string
+	// Scheme is basic, apikey or bearer
+	Scheme string
+	// Claims holds the verified claims of a JWT
+	Claims map[string]interface{}
+}
+
+// SetPrincipal store the authenticated principal on the context
+func (c *Context) SetPrincipal(p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// Principal returns the authenticated principal, nil if none
+func (c *Context) Principal() *Principal {
+	if v, ok := c.Get(principalKey); ok {
+		p, _ := v.(*Principal)
+		return p
+	}
+	return nil
+}
+
+// Accounts maps user names to passwords for BasicAuth
+type Accounts map[string]string
+
+// BasicAuth middleware checks the Authorization header against accounts
+func BasicAuth(accounts Accounts) HandlerFunc {
+	return BasicAuthForRealm(accounts, "Authorization Required")
+}
+
+// BasicAuthForRealm is BasicAuth with a custom realm
+func BasicAuthForRealm(accounts Accounts, realm string) HandlerFunc {
+	challenge := "Basic realm=" + strconv.Quote(realm)
+	type credential struct {
+		user string
+		hash [sha256.Size]byte
+	}
+	// 比较 sha256，长度固定，比较时间与密码内容无关
+	credentials := make([]credential, 0, len(accounts))
+	for user, password := range accounts {
+		credentials = append(credentials, credential{user: user, hash: sha256.Sum256([]byte(user + ":" + password))})
+	}
+	return func(c *Context) {
+		user, password, ok := c.Req.BasicAuth()
+		if ok {
+			hash := sha256.Sum256([]byte(user + ":" + password))
+			found := ""
+			for _, cred := range credentials {
+				if subtle.ConstantTimeCompare(hash[:], cred.hash[:]) == 1 {
+					found = cred.user
+				}
+			}
+			if found != "" {
+				c.SetPrincipal(&Principal{Subject: found, Scheme: "basic"})
+				c.Next()
+				return
+			}
+		}
+		c.SetHeader("WWW-Authenticate", challenge)
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+// APIKeyConfig configures APIKeyAuth
+type APIKeyConfig struct {
+	// Header carrying the key, default X-API-Key
+	Header string
+	// Query is the query parameter carrying the key, empty disables it
+	Query string
+	// Keys maps api keys to the subject of the principal
+	Keys map[string]string
+	// Validate checks keys not found in Keys, eg. against a database
+	Validate func(c *Context, key string) (subject string, ok bool)
+}
+
+// APIKeyAuth middleware accepts the requests carrying a known api key
+func APIKeyAuth(config APIKeyConfig) HandlerFunc {
+	if config.Header == "" {
+		config.Header = "X-API-Key"
+	}
+	type apiKey struct {
+		hash    [sha256.Size]byte
+		subject string
+	}
+	keys := make([]apiKey, 0, len(config.Keys))
+	for key, subject := range config.Keys {
+		keys = append(keys, apiKey{hash: sha256.Sum256([]byte(key)), subject: subject})
+	}
+	return func(c *Context) {
+		key := c.Req.Header.Get(config.Header)
+		if key == "" && config.Query != "" {
+			key = c.Query(config.Query)
+		}
+		if key != "" {
+			hash := sha256.Sum256([]byte(key))
+			subject, found := "", false
+			for _, k := range keys {
+				if subtle.ConstantTimeCompare(hash[:], k.hash[:]) == 1 {
+					subject, found = k.subject, true
+				}
+			}
+			if !found && config.Validate != nil {
+				subject, found = config.Validate(c, key)
+			}
+			if found {
+				c.SetPrincipal(&Principal{Subject: subject, Scheme: "apikey"})
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+// bearerToken returns the token of an Authorization: Bearer header
+func bearerToken(c *Context) string {
+	auth := c.Req.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// AllowIPs middleware rejects with 403 the clients whose ClientIP isn't in one of
+// the CIDRs, it panics on an invalid CIDR:
+//
+//	admin.Use(gee.AllowIPs("10.0.0.0/8", "192.168.1.10"))
+func AllowIPs(cidrs ...string) HandlerFunc {
+	nets, err := parseCIDRs(cidrs)
+	if err != nil {
+		panic(err)
+	}
+	return func(c *Context) {
+		if ip := net.ParseIP(c.ClientIP()); ip == nil || !containsIP(nets, ip) {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
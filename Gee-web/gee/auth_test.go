@@ -0,0 +1,162 @@
+package gee_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func principalHandler(c *gee.Context) {
+	p := c.Principal()
+	c.String(http.StatusOK, "%s %s", p.Scheme, p.Subject)
+}
+
+func TestBasicAuth(t *testing.T) {
+	r := gee.New()
+	r.GET("/", gee.BasicAuth(gee.Accounts{"tom": "secret"}), principalHandler)
+	client := geetest.New(t, r)
+	basic := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+	client.GET("/").Header("Authorization", basic("tom", "secret")).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("basic tom")
+	client.GET("/").Header("Authorization", basic("tom", "wrong")).Do().
+		ExpectStatus(http.StatusUnauthorized).
+		ExpectHeader("WWW-Authenticate", `Basic realm="Authorization Required"`)
+	client.GET("/").Do().ExpectStatus(http.StatusUnauthorized)
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	r := gee.New()
+	r.GET("/", gee.APIKeyAuth(gee.APIKeyConfig{
+		Query: "api_key",
+		Keys:  map[string]string{"k1": "billing"},
+		Validate: func(c *gee.Context, key string) (string, bool) {
+			return "dynamic", key == "k2"
+		},
+	}), principalHandler)
+	client := geetest.New(t, r)
+	client.GET("/").Header("X-API-Key", "k1").Do().ExpectStatus(http.StatusOK).ExpectBody("apikey billing")
+	client.GET("/").Query("api_key", "k2").Do().ExpectStatus(http.StatusOK).ExpectBody("apikey dynamic")
+	client.GET("/").Header("X-API-Key", "nope").Do().ExpectStatus(http.StatusUnauthorized)
+}
+
+func TestJWTAuth(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	keys := gee.NewKeySet(
+		gee.JWTKey{ID: "hs-old", Algorithm: "HS256", Key: []byte("old-secret")},
+		gee.JWTKey{ID: "hs-512", Algorithm: "HS512", Key: []byte("secret-512")},
+		gee.JWTKey{ID: "rs", Algorithm: "RS256", Key: &rsaKey.PublicKey},
+		gee.JWTKey{ID: "es", Algorithm: "ES256", Key: &ecKey.PublicKey},
+	)
+	config := gee.JWTConfig{
+		Keys:     keys,
+		Issuer:   "gee",
+		Audience: "api",
+		Now:      func() time.Time { return now },
+	}
+	r := gee.New()
+	r.GET("/", gee.JWTAuth(config), principalHandler)
+	client := geetest.New(t, r)
+
+	claims := func(extra gee.H) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "tom",
+			"iss": "gee",
+			"aud": []string{"web", "api"},
+			"exp": now.Add(time.Hour).Unix(),
+			"nbf": now.Add(-time.Minute).Unix(),
+		}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	sign := func(claims map[string]interface{}, key gee.JWTKey) string {
+		token, err := gee.SignJWT(claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + token
+	}
+	valid := []gee.JWTKey{
+		{ID: "hs-old", Algorithm: "HS256", Key: []byte("old-secret")},
+		{ID: "hs-512", Algorithm: "HS512", Key: []byte("secret-512")},
+		{ID: "rs", Algorithm: "RS256", Key: rsaKey},
+		{ID: "es", Algorithm: "ES256", Key: ecKey},
+	}
+	for _, key := range valid {
+		client.GET("/").Header("Authorization", sign(claims(nil), key)).Do().
+			ExpectStatus(http.StatusOK).
+			ExpectBody("bearer tom")
+	}
+
+	hs := valid[0]
+	invalid := map[string]string{
+		"expired":    sign(claims(gee.H{"exp": now.Add(-time.Second).Unix()}), hs),
+		"not yet":    sign(claims(gee.H{"nbf": now.Add(time.Minute).Unix()}), hs),
+		"issuer":     sign(claims(gee.H{"iss": "other"}), hs),
+		"audience":   sign(claims(gee.H{"aud": "web"}), hs),
+		"wrong key":  sign(claims(nil), gee.JWTKey{ID: "hs-old", Algorithm: "HS256", Key: []byte("guess")}),
+		"unknown id": sign(claims(nil), gee.JWTKey{ID: "hs-new", Algorithm: "HS256", Key: []byte("new-secret")}),
+		"alg none":   "Bearer eyJhbGciOiJub25lIn0.eyJzdWIiOiJ0b20ifQ.",
+		"malformed":  "Bearer abc",
+	}
+	for name, auth := range invalid {
+		res := client.GET("/").Header("Authorization", auth).Do()
+		if res.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status = %d, want 401", name, res.Code)
+		}
+	}
+
+	// 轮换密钥：加入新的 kid 后新旧 token 都有效，删除旧 key 后旧 token 失效
+	newKey := gee.JWTKey{ID: "hs-new", Algorithm: "HS256", Key: []byte("new-secret")}
+	keys.Add(newKey)
+	client.GET("/").Header("Authorization", sign(claims(nil), newKey)).Do().ExpectStatus(http.StatusOK)
+	keys.Remove("hs-old")
+	res := client.GET("/").Header("Authorization", sign(claims(nil), hs)).Do().ExpectStatus(http.StatusUnauthorized)
+	if !strings.Contains(res.Header().Get("WWW-Authenticate"), "invalid_token") {
+		t.Errorf("WWW-Authenticate = %q", res.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestAllowIPs(t *testing.T) {
+	r := gee.New()
+	r.SetTrustedProxies("10.0.0.1")
+	r.GET("/admin", gee.AllowIPs("192.168.1.0/24", "2001:db8::1"), func(c *gee.Context) {
+		c.String(http.StatusOK, "admin")
+	})
+	client := geetest.New(t, r)
+
+	client.GET("/admin").RemoteAddr("192.168.1.20:1000").Do().ExpectStatus(http.StatusOK)
+	client.GET("/admin").RemoteAddr("[2001:db8::1]:1000").Do().ExpectStatus(http.StatusOK)
+	client.GET("/admin").RemoteAddr("192.168.2.20:1000").Do().ExpectStatus(http.StatusForbidden)
+	client.GET("/admin").RemoteAddr("10.0.0.1:80").Header("X-Forwarded-For", "192.168.1.20").Do().
+		ExpectStatus(http.StatusOK)
+	client.GET("/admin").RemoteAddr("203.0.113.1:80").Header("X-Forwarded-For", "192.168.1.20").Do().
+		ExpectStatus(http.StatusForbidden)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AllowIPs accepted an invalid CIDR")
+		}
+	}()
+	gee.AllowIPs("192.168.1.0/99")
+}
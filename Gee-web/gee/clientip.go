@@ -0,0 +1,248 @@
+package gee
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Headers read from the trusted proxies
+const (
+	HeaderForwarded       = "Forwarded"
+	HeaderXForwardedFor   = "X-Forwarded-For"
+	HeaderXForwardedProto = "X-Forwarded-Proto"
+	HeaderXForwardedHost  = "X-Forwarded-Host"
+	HeaderXRealIP         = "X-Real-IP"
+)
+
+// defaultRemoteIPHeaders are the headers of the client IP, in order of preference.
+// Forwarded is left out, few proxies strip the one sent by the client, so it is
+// opt-in through Engine.RemoteIPHeaders
+var defaultRemoteIPHeaders = []string{HeaderXForwardedFor, HeaderXRealIP}
+
+// SetTrustedProxies set the proxies whose forwarding headers are believed, as CIDRs
+// or single IPs, eg. "10.0.0.0/8" or "127.0.0.1". No trusted proxy is the default:
+// ClientIP, Scheme and Host then only come from the connection and the request line
+//
+//	r.SetTrustedProxies("10.0.0.0/8", "fd00::/8")
+func (engine *Engine) SetTrustedProxies(proxies ...string) error {
+	nets, err := parseCIDRs(proxies)
+	if err != nil {
+		return err
+	}
+	engine.trustedProxies = nets
+	return nil
+}
+
+// parseCIDRs parse a list of CIDRs, a single IP is a network of one address
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, cidr := range list {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("gee: invalid IP %q", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("gee: invalid CIDR %q: %w", cidr, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// containsIP reports whether one of nets contains ip
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTrustedProxy reports whether ip belongs to a trusted proxy
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	if engine == nil || ip == nil {
+		return false
+	}
+	return containsIP(engine.trustedProxies, ip)
+}
+
+// remoteIPHeaders returns Engine.RemoteIPHeaders or the default ones
+func (engine *Engine) remoteIPHeaders() []string {
+	if engine.RemoteIPHeaders == nil {
+		return defaultRemoteIPHeaders
+	}
+	return engine.RemoteIPHeaders
+}
+
+// readsHeader reports whether the header is one of the remote IP headers
+func (engine *Engine) readsHeader(name string) bool {
+	for _, header := range engine.remoteIPHeaders() {
+		if strings.EqualFold(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the IP of the client. The forwarding headers of
+// Engine.RemoteIPHeaders are only read when the peer of the connection is a
+// trusted proxy, and they are walked from the right, the closest hop, to the
+// first address which isn't a trusted proxy, so a client can't spoof its IP
+// by sending the headers itself
+func (c *Context) ClientIP() string {
+	remote := remoteIP(c.Req.RemoteAddr)
+	if !c.engine.isTrustedProxy(remote) {
+		if remote == nil {
+			return ""
+		}
+		return remote.String()
+	}
+	for _, name := range c.engine.remoteIPHeaders() {
+		switch http.CanonicalHeaderKey(name) {
+		case HeaderForwarded:
+			elements := parseForwarded(c.Req.Header.Values(HeaderForwarded))
+			fors := make([]string, len(elements))
+			for i, element := range elements {
+				fors[i] = element["for"]
+			}
+			if ip, _ := c.walkProxies(fors); ip != nil {
+				return ip.String()
+			}
+		case HeaderXRealIP:
+			if ip := parseForwardedIP(c.Req.Header.Get(HeaderXRealIP)); ip != nil {
+				return ip.String()
+			}
+		default:
+			if ip, _ := c.walkProxies(splitHeader(c.Req.Header.Values(name))); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return remote.String()
+}
+
+// Scheme returns "https" or "http" as the client sent the request, a trusted proxy
+// tells it with the proto of Forwarded or X-Forwarded-Proto
+func (c *Context) Scheme() string {
+	if proto := c.forwarded("proto", HeaderXForwardedProto); proto != "" {
+		return strings.ToLower(proto)
+	}
+	if c.Req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// Host returns the host the client requested, a trusted proxy
+// tells it with the host of Forwarded or X-Forwarded-Host
+func (c *Context) Host() string {
+	if host := c.forwarded("host", HeaderXForwardedHost); host != "" {
+		return host
+	}
+	return c.Req.Host
+}
+
+// forwarded returns the param of the Forwarded element of the client hop,
+// or the closest value of the X-Forwarded header, when the peer is a trusted proxy
+func (c *Context) forwarded(param, header string) string {
+	if !c.engine.isTrustedProxy(remoteIP(c.Req.RemoteAddr)) {
+		return ""
+	}
+	elements := parseForwarded(c.Req.Header.Values(HeaderForwarded))
+	if len(elements) > 0 && c.engine.readsHeader(HeaderForwarded) {
+		fors := make([]string, len(elements))
+		for i, element := range elements {
+			fors[i] = element["for"]
+		}
+		if _, i := c.walkProxies(fors); i >= 0 {
+			return elements[i][param]
+		}
+		// 没有 for 的 Forwarded 由最近的代理添加
+		if _, ok := elements[len(elements)-1]["for"]; !ok {
+			return elements[len(elements)-1][param]
+		}
+	}
+	values := splitHeader(c.Req.Header.Values(header))
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// walkProxies returns the first address from the right which isn't a trusted proxy
+// and its index, or the leftmost one when they are all trusted; an invalid address
+// on the way makes the header unusable
+func (c *Context) walkProxies(hops []string) (net.IP, int) {
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseForwardedIP(hops[i])
+		if ip == nil {
+			return nil, -1
+		}
+		if i == 0 || !c.engine.isTrustedProxy(ip) {
+			return ip, i
+		}
+	}
+	return nil, -1
+}
+
+// parseForwarded parse the elements of RFC 7239 Forwarded headers,
+// the param names are lower case and the quoted values are unquoted
+func parseForwarded(values []string) []map[string]string {
+	var elements []map[string]string
+	for _, element := range splitHeader(values) {
+		params := make(map[string]string)
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if !ok {
+				continue
+			}
+			params[strings.ToLower(name)] = strings.Trim(value, `"`)
+		}
+		elements = append(elements, params)
+	}
+	return elements
+}
+
+// parseForwardedIP parse a node of Forwarded or an address of X-Forwarded-For:
+// 1.2.3.4, 1.2.3.4:80, [::1] or [::1]:80; "unknown" and obfuscated nodes are nil
+func parseForwardedIP(node string) net.IP {
+	node = strings.TrimSpace(node)
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(node, "["), "]"))
+}
+
+// remoteIP returns the IP of a RemoteAddr
+func remoteIP(addr string) net.IP {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return net.ParseIP(host)
+}
+
+// splitHeader split the comma separated lists of the values of a header
+func splitHeader(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+	return items
+}
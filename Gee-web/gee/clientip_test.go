@@ -0,0 +1,115 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"testing"
+)
+
+type clientIPTest struct {
+	name   string
+	remote string
+	header map[string]string
+	want   string
+}
+
+func TestClientIP(t *testing.T) {
+	r := gee.New()
+	if err := r.SetTrustedProxies("10.0.0.0/8", "::1"); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/ip", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s %s %s", c.ClientIP(), c.Scheme(), c.Host())
+	})
+	client := geetest.New(t, r)
+
+	tests := []clientIPTest{
+		{"direct", "203.0.113.9:4000", nil, "203.0.113.9 http example.com"},
+		{"untrusted peer spoofing", "203.0.113.9:4000",
+			map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "evil.com"},
+			"203.0.113.9 http example.com"},
+		{"x-forwarded-for", "10.0.0.2:80",
+			map[string]string{"X-Forwarded-For": "198.51.100.7, 10.0.0.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "shop.example.com"},
+			"198.51.100.7 https shop.example.com"},
+		// 客户端伪造的最左地址被忽略
+		{"x-forwarded-for spoofed by the client", "10.0.0.2:80",
+			map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7"},
+			"198.51.100.7 http example.com"},
+		{"all trusted", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.1"}, "10.0.0.5 http example.com"},
+		{"invalid x-forwarded-for", "10.0.0.2:80",
+			map[string]string{"X-Forwarded-For": "unknown", "X-Real-IP": "198.51.100.8"},
+			"198.51.100.8 http example.com"},
+		{"x-real-ip", "[::1]:80", map[string]string{"X-Real-IP": "2001:db8::7"}, "2001:db8::7 http example.com"},
+		// Forwarded 默认不读取，客户端自己发送的 Forwarded 不能覆盖代理设置的 X-Forwarded-For
+		{"client supplied forwarded", "10.0.0.2:80",
+			map[string]string{"Forwarded": "for=1.2.3.4;proto=https;host=evil.com", "X-Forwarded-For": "198.51.100.7"},
+			"198.51.100.7 http example.com"},
+	}
+	run := func(tests []clientIPTest) {
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				b := client.GET("/ip").RemoteAddr(tt.remote)
+				for k, v := range tt.header {
+					b.Header(k, v)
+				}
+				b.Do().ExpectBody(tt.want)
+			})
+		}
+	}
+	run(tests)
+
+	r.RemoteIPHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+	run([]clientIPTest{
+		{"forwarded", "10.0.0.2:80",
+			map[string]string{
+				"Forwarded":       `for=1.2.3.4;proto=http, for="[2001:db8::1]:4711";proto=https;host=api.example.com, for=10.0.0.1;proto=http`,
+				"X-Forwarded-For": "9.9.9.9",
+			},
+			"2001:db8::1 https api.example.com"},
+		{"forwarded without for", "10.0.0.2:80", map[string]string{"Forwarded": "proto=https"}, "10.0.0.2 https example.com"},
+		{"obfuscated forwarded", "10.0.0.2:80",
+			map[string]string{"Forwarded": "for=_hidden", "X-Forwarded-For": "198.51.100.9"},
+			"198.51.100.9 http example.com"},
+	})
+
+	r.RemoteIPHeaders = []string{"X-Real-IP"}
+	client.GET("/ip").RemoteAddr("10.0.0.2:80").
+		Header("X-Forwarded-For", "198.51.100.7").
+		Header("Forwarded", "for=198.51.100.7;host=evil.com").
+		Do().ExpectBody("10.0.0.2 http example.com")
+
+	if err := r.SetTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("SetTrustedProxies accepted an invalid CIDR")
+	}
+	if err := r.SetTrustedProxies("localhost"); err == nil {
+		t.Error("SetTrustedProxies accepted a host name")
+	}
+}
+
+func TestClientIPResolverUsers(t *testing.T) {
+	r := gee.New()
+	r.SetTrustedProxies("127.0.0.1")
+	r.Use(gee.SecureWithConfig(gee.SecureConfig{
+		HSTS:         "max-age=60",
+		SSLRedirect:  true,
+		AllowedHosts: []string{"www.example.com"},
+	}))
+	gee.RegisterDebug(r.RouterGroup, gee.DebugConfig{})
+	client := geetest.New(t, r)
+
+	// 代理终止 TLS，X-Forwarded-Proto 标记 https
+	client.GET("/debug/stats").RemoteAddr("127.0.0.1:80").
+		Header("X-Forwarded-Proto", "https").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Strict-Transport-Security", "max-age=60")
+	client.GET("/debug/stats").RemoteAddr("127.0.0.1:80").
+		Header("X-Forwarded-Host", "www.example.com").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "https://www.example.com/debug/stats")
+	// 经过本机代理的外部客户端不是 loopback
+	client.GET("/debug/stats").RemoteAddr("127.0.0.1:80").
+		Header("X-Forwarded-Proto", "https").
+		Header("X-Forwarded-For", "203.0.113.9").Do().
+		ExpectStatus(http.StatusForbidden)
+}
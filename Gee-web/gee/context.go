@@ -1,11 +1,17 @@
 package gee
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"gee/internal/templatehook"
+	"math"
 	"net/http"
 )
 
+// abortIndex is larger than any handlers chain
+const abortIndex int = math.MaxInt32 >> 1
+
 type Context struct {
 	// origin objects
 	Writer http.ResponseWriter
@@ -14,19 +20,32 @@ type Context struct {
 	Path   string
 	Method string
 	Params map[string]string
+	// fullPath is the pattern of the matched route
+	fullPath string
 	// response info
 	StatusCode int
+	writer     *responseWriter
 	// middlewares
 	handlers []HandlerFunc
 	index    int
+	// errors collected by c.Error
+	Errors errorMsgs
+	// Keys holds the values shared by the handlers of a request
+	Keys map[string]interface{}
 	// engine pointer
 	engine *Engine
+	// body bounds c.Req.Body with the engine limits, nil without body
+	body *limitedBody
+	// host matched by Engine.Host, nil if none
+	hostRoute *hostRoute
 }
 
 // newContext create context
 func newContext(w http.ResponseWriter, req *http.Request) *Context {
+	writer := newResponseWriter(w)
 	return &Context{
-		Writer: w,
+		Writer: writer,
+		writer: writer,
 		Req:    req,
 		Path:   req.URL.Path,
 		Method: req.Method,
@@ -45,17 +64,99 @@ func (c *Context) Next() {
 	}
 }
 
+// Abort prevents pending handlers from being called
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// IsAborted returns true if the current context was aborted
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// AbortWithStatus write the status code and abort
+func (c *Context) AbortWithStatus(code int) {
+	c.Status(code)
+	c.Abort()
+}
+
+// AbortWithError collect err with the status code and abort,
+// the response is left to the ErrorHandler
+func (c *Context) AbortWithError(code int, err error) *Error {
+	c.Abort()
+	return c.Error(err).SetStatus(code)
+}
+
+// Error attach an error to the current context, it's private by default
+func (c *Context) Error(err error) *Error {
+	if err == nil {
+		panic("gee: err is nil")
+	}
+	e := asError(err)
+	for _, collected := range c.Errors {
+		if collected == e {
+			return e
+		}
+	}
+	c.Errors = append(c.Errors, e)
+	return e
+}
+
+// Written reports whether the response header has been written
+func (c *Context) Written() bool {
+	return c.writer.Written()
+}
+
+// Set store a value for the handlers running later
+func (c *Context) Set(key string, value interface{}) {
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+// Get returns the value stored by c.Set
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	value, exists = c.Keys[key]
+	return
+}
+
+// FullPath returns the pattern of the matched route, eg. "/user/:id",
+// it's empty when no route matches
+func (c *Context) FullPath() string {
+	return c.fullPath
+}
+
 // Param get param
 func (c *Context) Param(key string) string {
 	value, _ := c.Params[key]
 	return value
 }
 
-// PostForm get form key
+// PostForm get form key. A body over the engine limits aborts the request with
+// 413 or 408, other errors of reading the body are ignored, c.Req.ParseForm returns them
 func (c *Context) PostForm(key string) string {
+	// ParseMultipartForm drops the errors of ParseForm
+	err := c.Req.ParseForm()
+	if err == nil {
+		err = c.Req.ParseMultipartForm(defaultMultipartMemory)
+	}
+	if err != nil {
+		c.abortBody(err)
+	}
 	return c.Req.FormValue(key)
 }
 
+// BindJSON decode the JSON body into obj. A body over the engine limits aborts
+// the request with 413 or 408, the other errors are left to the handler
+func (c *Context) BindJSON(obj interface{}) error {
+	err := json.NewDecoder(c.Req.Body).Decode(obj)
+	if err != nil {
+		c.abortBody(err)
+	}
+	return err
+}
+
 // Query get query key
 func (c *Context) Query(key string) string {
 	return c.Req.URL.Query().Get(key)
@@ -90,6 +191,7 @@ func (c *Context) JSON(code int, obj interface{}) {
 
 // Fail return fail
 func (c *Context) Fail(code int, err string) {
+	c.StatusCode = code
 	http.Error(c.Writer, err, code)
 }
 
@@ -99,11 +201,27 @@ func (c *Context) Data(code int, data []byte) {
 	c.Writer.Write(data)
 }
 
-// HTML html
+// HTML render the template name, nothing is written when rendering fails
 func (c *Context) HTML(code int, name string, data interface{}) {
-	c.SetHeader("Content-Type", "text/html")
-	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
+	if c.engine.htmlRender == nil {
+		c.Fail(500, "gee: no html templates loaded")
+		return
+	}
+	templatehook.Call(c.Req.Context(), name)
+	tmpl, err := c.engine.htmlRender.Lookup(name, c.engine.HTMLAutoReload)
+	if err != nil {
+		c.Fail(500, err.Error())
+		return
+	}
+	// 克隆后绑定当前请求的内置 func，未执行过的模板才能克隆
+	if clone, err := tmpl.Clone(); err == nil {
+		tmpl = clone.Funcs(requestFuncs(c))
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
 		c.Fail(500, err.Error())
+		return
 	}
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.Data(code, buf.Bytes())
 }
@@ -0,0 +1,126 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestContextRender(t *testing.T) {
+	r := gee.New()
+	r.GET("/json", func(c *gee.Context) {
+		c.JSON(http.StatusCreated, gee.H{"name": c.Query("name"), "tags": []string{"a", "b"}})
+	})
+	r.POST("/form", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s:%s", c.PostForm("user"), c.Req.Header.Get("X-Token"))
+	})
+	r.GET("/data", func(c *gee.Context) {
+		c.Data(http.StatusAccepted, []byte("raw"))
+	})
+	client := geetest.New(t, r)
+	client.GET("/json").Query("name", "tom").Do().
+		ExpectStatus(http.StatusCreated).
+		ExpectHeader("Content-Type", "application/json").
+		ExpectJSON("name", "tom").
+		ExpectJSON("tags.1", "b")
+	client.POST("/form").Form(url.Values{"user": {"tom"}}).Header("X-Token", "t1").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("tom:t1")
+	client.GET("/data").Do().ExpectStatus(http.StatusAccepted).ExpectBody("raw")
+}
+
+func TestContextHTML(t *testing.T) {
+	r := gee.New()
+	templates := fstest.MapFS{
+		"index.html": {Data: []byte(`hello {{.}}`)},
+	}
+	if err := r.LoadHTMLFS(templates, "*.html"); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "index.html", "tom")
+	})
+	r.GET("/missing", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "missing.html", nil)
+	})
+	client := geetest.New(t, r)
+	client.GET("/").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectTemplate("index.html").
+		ExpectBody("hello tom")
+	client.GET("/missing").Do().ExpectStatus(http.StatusInternalServerError)
+
+	if err := r.LoadHTMLGlob("no-such-dir/*"); err == nil {
+		t.Error("LoadHTMLGlob should fail for a missing directory")
+	}
+}
+
+func TestContextHTMLConcurrentTemplates(t *testing.T) {
+	r := gee.New()
+	if err := r.LoadHTMLFS(fstest.MapFS{
+		"a.html": {Data: []byte(`a`)},
+		"b.html": {Data: []byte(`b`)},
+	}, "*.html"); err != nil {
+		t.Fatal(err)
+	}
+	// 所有请求都到达后才渲染，保证它们同时进行
+	const n = 16
+	var arrived sync.WaitGroup
+	arrived.Add(n)
+	r.GET("/:page", func(c *gee.Context) {
+		arrived.Done()
+		arrived.Wait()
+		c.HTML(http.StatusOK, c.Param("page")+".html", nil)
+	})
+	client := geetest.New(t, r)
+
+	// 每个请求只记录自己渲染的模板
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		page := []string{"a", "b"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := client.GET("/" + page).Do().ExpectBody(page)
+			if len(res.Templates) != 1 || res.Templates[0] != page+".html" {
+				t.Errorf("templates of /%s = %v", page, res.Templates)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestContextAbort(t *testing.T) {
+	r := gee.New()
+	r.Use(func(c *gee.Context) {
+		if c.Query("token") == "" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	})
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	client := geetest.New(t, r)
+	client.GET("/").Do().ExpectStatus(http.StatusUnauthorized).ExpectBody("")
+	client.GET("/").Query("token", "1").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestCreateTestContext(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := geetest.CreateTestContext(w)
+	c.Params = map[string]string{"id": "7"}
+	c.JSON(http.StatusOK, gee.H{"id": c.Param("id")})
+	if w.Code != http.StatusOK || w.Body.String() != "{\"id\":\"7\"}\n" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+	if !c.Written() {
+		t.Error("context should be written")
+	}
+}
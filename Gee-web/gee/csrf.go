@@ -0,0 +1,352 @@
+package gee
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+const (
+	csrfTokenKey = "gee/csrf-token"
+	csrfFieldKey = "gee/csrf-field"
+	csrfTokenLen = 32
+)
+
+// ErrCSRFToken is collected by the CSRF middleware when the token is missing or wrong
+var ErrCSRFToken = errors.New("csrf: invalid token")
+
+// CSRFStore keeps the real token of a client. The default store is a cookie,
+// which is the double submit cookie pattern; a store backed by the server side
+// session gives the synchronizer token pattern
+type CSRFStore interface {
+	// Get returns the token saved for the client, empty if none
+	Get(c *Context) string
+	// Save keeps a new token for the client
+	Save(c *Context, token string)
+}
+
+// CSRFConfig configures the CSRF middleware
+type CSRFConfig struct {
+	// Store keeps the real token, default a CSRFCookieStore built from the Cookie fields
+	Store CSRFStore
+	// Secret signs the token cookie, so sibling subdomains can't plant a token of their own
+	Secret []byte
+	// CookieName default "_csrf"
+	CookieName string
+	// CookiePath default "/"
+	CookiePath   string
+	CookieDomain string
+	CookieSecure bool
+	// CookieSameSite default http.SameSiteLaxMode
+	CookieSameSite http.SameSite
+	// CookieMaxAge in seconds, 0 keeps the cookie for the browser session
+	CookieMaxAge int
+	// FormField is the form field of the token, default "_csrf"
+	FormField string
+	// Header carries the token of AJAX requests, default "X-CSRF-Token"
+	Header string
+	// Exempt lists path prefixes which are not checked, eg. webhooks; they match
+	// whole segments, /hooks exempts /hooks/github but not /hooks-admin
+	Exempt []string
+	// Skipper skips the check when it returns true
+	Skipper func(c *Context) bool
+	// Handler writes the response when the token is invalid,
+	// the default answers 403 Forbidden
+	Handler HandlerFunc
+}
+
+// CSRF middleware protects unsafe methods with a token, the token of the
+// request is read from the header first, then from the form field:
+//
+//	r.Use(gee.CSRF())
+//	<form method="post">{{ csrfField }}</form>
+//
+// csrfField is bound to the Context of c.HTML and uses CSRFConfig.FormField
+func CSRF() HandlerFunc {
+	return CSRFWithConfig(CSRFConfig{})
+}
+
+// CSRFWithConfig returns a CSRF middleware with config
+func CSRFWithConfig(config CSRFConfig) HandlerFunc {
+	if config.FormField == "" {
+		config.FormField = "_csrf"
+	}
+	if config.Header == "" {
+		config.Header = "X-CSRF-Token"
+	}
+	if config.Store == nil {
+		config.Store = &CSRFCookieStore{
+			Name:     config.CookieName,
+			Path:     config.CookiePath,
+			Domain:   config.CookieDomain,
+			Secure:   config.CookieSecure,
+			SameSite: config.CookieSameSite,
+			MaxAge:   config.CookieMaxAge,
+			Secret:   config.Secret,
+		}
+	}
+	if config.Handler == nil {
+		config.Handler = func(c *Context) {
+			c.String(http.StatusForbidden, "403 FORBIDDEN: %s\n", ErrCSRFToken)
+		}
+	}
+	return func(c *Context) {
+		if config.Skipper != nil && config.Skipper(c) {
+			c.Next()
+			return
+		}
+		for _, prefix := range config.Exempt {
+			if hasPathPrefix(c.Path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		token, err := base64.RawURLEncoding.DecodeString(config.Store.Get(c))
+		if err != nil || len(token) != csrfTokenLen {
+			token = make([]byte, csrfTokenLen)
+			if _, err := rand.Read(token); err != nil {
+				panic(err)
+			}
+			config.Store.Save(c, base64.RawURLEncoding.EncodeToString(token))
+		}
+		c.Set(csrfTokenKey, maskCSRFToken(token))
+		c.Set(csrfFieldKey, config.FormField)
+
+		switch c.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			c.Next()
+			return
+		}
+		sent := c.Req.Header.Get(config.Header)
+		if sent == "" {
+			sent = c.Req.PostFormValue(config.FormField)
+		}
+		if !validCSRFToken(token, sent) {
+			c.Abort()
+			c.Error(ErrCSRFToken).SetStatus(http.StatusForbidden)
+			config.Handler(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken returns the token to send back with the next unsafe request,
+// it's masked differently on every call so it can't be guessed from compressed responses
+func CSRFToken(c *Context) string {
+	v, _ := c.Get(csrfTokenKey)
+	token, _ := v.(string)
+	return token
+}
+
+// CSRFField returns the hidden input carrying the token of c
+func CSRFField(c *Context) template.HTML {
+	field, _ := c.Get(csrfFieldKey)
+	name, _ := field.(string)
+	return csrfInput(name, CSRFToken(c))
+}
+
+// csrfField is the template func, c is the Context rendering the template,
+// nil outside of c.HTML. It also accepts a Context or a token argument
+func csrfField(c *Context, args ...interface{}) template.HTML {
+	if len(args) == 0 {
+		if c == nil {
+			return ""
+		}
+		return CSRFField(c)
+	}
+	switch v := args[0].(type) {
+	case *Context:
+		return CSRFField(v)
+	case string:
+		name := ""
+		if c != nil {
+			field, _ := c.Get(csrfFieldKey)
+			name, _ = field.(string)
+		}
+		return csrfInput(name, v)
+	}
+	return ""
+}
+
+func csrfInput(name, token string) template.HTML {
+	if token == "" {
+		return ""
+	}
+	if name == "" {
+		name = "_csrf"
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(name) +
+		`" value="` + template.HTMLEscapeString(token) + `">`)
+}
+
+// maskCSRFToken returns base64(pad + (pad xor token)) with a random pad
+func maskCSRFToken(token []byte) string {
+	masked := make([]byte, 2*len(token))
+	if _, err := rand.Read(masked[:len(token)]); err != nil {
+		panic(err)
+	}
+	for i, b := range token {
+		masked[len(token)+i] = masked[i] ^ b
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// validCSRFToken compare the masked or raw token sent by the client
+func validCSRFToken(token []byte, sent string) bool {
+	data, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil {
+		return false
+	}
+	switch len(data) {
+	case len(token):
+	case 2 * len(token):
+		pad, masked := data[:len(token)], data[len(token):]
+		for i := range masked {
+			masked[i] ^= pad[i]
+		}
+		data = masked
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare(data, token) == 1
+}
+
+// CSRFCookieStore keeps the token in a HttpOnly cookie
+type CSRFCookieStore struct {
+	// Name default "_csrf"
+	Name string
+	// Path default "/"
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+	MaxAge   int
+	// Secret signs the cookie when not empty
+	Secret []byte
+}
+
+// Get implements CSRFStore
+func (s *CSRFCookieStore) Get(c *Context) string {
+	cookie, err := c.Req.Cookie(s.name())
+	if err != nil {
+		return ""
+	}
+	if len(s.Secret) == 0 {
+		return cookie.Value
+	}
+	i := strings.LastIndexByte(cookie.Value, '.')
+	if i < 0 {
+		return ""
+	}
+	token, sig := cookie.Value[:i], cookie.Value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.sign(token))) {
+		return ""
+	}
+	return token
+}
+
+// Save implements CSRFStore
+func (s *CSRFCookieStore) Save(c *Context, token string) {
+	value := token
+	if len(s.Secret) > 0 {
+		value += "." + s.sign(token)
+	}
+	path := s.Path
+	if path == "" {
+		path = "/"
+	}
+	sameSite := s.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     s.name(),
+		Value:    value,
+		Path:     path,
+		Domain:   s.Domain,
+		MaxAge:   s.MaxAge,
+		Secure:   s.Secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	})
+}
+
+func (s *CSRFCookieStore) name() string {
+	if s.Name == "" {
+		return "_csrf"
+	}
+	return s.Name
+}
+
+func (s *CSRFCookieStore) sign(token string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// CSRFSession is the server side session of a client, as provided by a session middleware
+type CSRFSession interface {
+	Get(key string) interface{}
+	Set(key string, value interface{})
+}
+
+// CSRFSessionStore keeps the token in the CSRFSession which a session middleware
+// put in the Keys of the Context, this is the synchronizer token pattern:
+//
+//	r.Use(sessions(), gee.CSRFWithConfig(gee.CSRFConfig{Store: &gee.CSRFSessionStore{}}))
+//
+// requests without a session get no token, so their unsafe requests are rejected
+type CSRFSessionStore struct {
+	// SessionKey is the key of the CSRFSession in Context.Keys, default "session"
+	SessionKey string
+	// Key is the key of the token in the session, default "csrf_token"
+	Key string
+}
+
+// Get implements CSRFStore
+func (s *CSRFSessionStore) Get(c *Context) string {
+	session := s.session(c)
+	if session == nil {
+		return ""
+	}
+	token, _ := session.Get(s.key()).(string)
+	return token
+}
+
+// Save implements CSRFStore
+func (s *CSRFSessionStore) Save(c *Context, token string) {
+	if session := s.session(c); session != nil {
+		session.Set(s.key(), token)
+	}
+}
+
+func (s *CSRFSessionStore) session(c *Context) CSRFSession {
+	name := s.SessionKey
+	if name == "" {
+		name = "session"
+	}
+	v, _ := c.Get(name)
+	session, _ := v.(CSRFSession)
+	return session
+}
+
+func (s *CSRFSessionStore) key() string {
+	if s.Key == "" {
+		return "csrf_token"
+	}
+	return s.Key
+}
+
+// hasPathPrefix reports whether prefix is made of the leading segments of path
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
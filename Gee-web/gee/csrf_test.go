@@ -0,0 +1,133 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCSRF(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.CSRFWithConfig(gee.CSRFConfig{
+		Secret: []byte("secret"),
+		Exempt: []string{"/hooks"},
+	}))
+	if err := r.LoadHTMLFS(fstest.MapFS{
+		"form.tmpl": {Data: []byte(`<form method="post">{{ csrfField }}</form>`)},
+	}, "*.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/form", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "form.tmpl", nil)
+	})
+	r.GET("/token", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s", gee.CSRFToken(c))
+	})
+	ok := func(c *gee.Context) { c.String(http.StatusOK, "ok") }
+	r.POST("/form", ok)
+	r.POST("/hooks/github", ok)
+	r.POST("/hooks-admin", ok)
+	client := geetest.New(t, r)
+
+	res := client.GET("/form").Do().ExpectStatus(http.StatusOK)
+	cookie := res.Header().Get("Set-Cookie")
+	if cookie == "" {
+		t.Fatal("no csrf cookie")
+	}
+	m := regexp.MustCompile(`<input type="hidden" name="_csrf" value="([^"]+)">`).FindStringSubmatch(res.Body.String())
+	if m == nil {
+		t.Fatalf("no csrf field in %q", res.Body.String())
+	}
+	jar := (&http.Response{Header: http.Header{"Set-Cookie": {cookie}}}).Cookies()[0]
+	cookieHeader := jar.Name + "=" + jar.Value
+
+	// 表单字段
+	client.POST("/form").Header("Cookie", cookieHeader).Form(url.Values{"_csrf": {m[1]}}).Do().
+		ExpectStatus(http.StatusOK)
+	// AJAX 请求头，每次拿到的 token 都不同但都有效
+	token := client.GET("/token").Header("Cookie", cookieHeader).Do().Body.String()
+	if token == m[1] {
+		t.Error("token is not masked per request")
+	}
+	client.POST("/form").Header("Cookie", cookieHeader).Header("X-CSRF-Token", token).Do().
+		ExpectStatus(http.StatusOK)
+
+	client.POST("/form").Header("Cookie", cookieHeader).Do().ExpectStatus(http.StatusForbidden)
+	client.POST("/form").Form(url.Values{"_csrf": {m[1]}}).Do().ExpectStatus(http.StatusForbidden)
+	client.POST("/form").Header("Cookie", "_csrf=forged").Header("X-CSRF-Token", token).Do().
+		ExpectStatus(http.StatusForbidden)
+	client.POST("/hooks/github").Do().ExpectStatus(http.StatusOK)
+	// Exempt 按整个路径段匹配
+	client.POST("/hooks-admin").Do().ExpectStatus(http.StatusForbidden)
+}
+
+// mapSession is the session kept on the server by the sessions middleware
+type mapSession map[string]interface{}
+
+func (s mapSession) Get(key string) interface{} { return s[key] }
+
+func (s mapSession) Set(key string, value interface{}) { s[key] = value }
+
+// sessions put the session named by the X-Session header in the Keys
+func sessions() gee.HandlerFunc {
+	store := map[string]mapSession{}
+	return func(c *gee.Context) {
+		if id := c.Req.Header.Get("X-Session"); id != "" {
+			if store[id] == nil {
+				store[id] = mapSession{}
+			}
+			c.Set("session", store[id])
+		}
+		c.Next()
+	}
+}
+
+func TestCSRFSessionStore(t *testing.T) {
+	r := gee.New()
+	r.Use(sessions(), gee.CSRFWithConfig(gee.CSRFConfig{
+		Store:     &gee.CSRFSessionStore{},
+		FormField: "token",
+		Skipper:   func(c *gee.Context) bool { return c.Req.Header.Get("Authorization") != "" },
+		Handler: func(c *gee.Context) {
+			c.JSON(http.StatusForbidden, gee.H{"error": c.Errors.Last().Error()})
+		},
+	}))
+	if err := r.LoadHTMLFS(fstest.MapFS{
+		"form.tmpl": {Data: []byte(`{{ csrfField }}|{{ csrfField "raw" }}`)},
+	}, "*.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/form", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "form.tmpl", nil)
+	})
+	r.GET("/token", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s", gee.CSRFToken(c))
+	})
+	r.POST("/", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	client := geetest.New(t, r)
+
+	res := client.GET("/form").Header("X-Session", "s1").Do()
+	if res.Header().Get("Set-Cookie") != "" {
+		t.Error("session store set a cookie")
+	}
+	// 字段名取自 CSRFConfig.FormField
+	m := regexp.MustCompile(`^<input type="hidden" name="token" value="([^"]+)">\|<input type="hidden" name="token" value="raw">$`).
+		FindStringSubmatch(res.Body.String())
+	if m == nil {
+		t.Fatalf("unexpected form %q", res.Body.String())
+	}
+	client.POST("/").Header("X-Session", "s1").Form(url.Values{"token": {m[1]}}).Do().ExpectStatus(http.StatusOK)
+
+	token := client.GET("/token").Header("X-Session", "s1").Do().Body.String()
+	client.POST("/").Header("X-Session", "s1").Header("X-CSRF-Token", token).Do().ExpectStatus(http.StatusOK)
+	client.POST("/").Header("X-Session", "s2").Header("X-CSRF-Token", token).Do().
+		ExpectStatus(http.StatusForbidden).
+		ExpectJSON("error", gee.ErrCSRFToken.Error())
+	// 没有 session 的请求拿不到 token
+	client.POST("/").Header("X-CSRF-Token", token).Do().ExpectStatus(http.StatusForbidden)
+	client.POST("/").Header("Authorization", "Bearer t").Do().ExpectStatus(http.StatusOK)
+}
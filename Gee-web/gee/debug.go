@@ -0,0 +1,115 @@
+package gee
+
+import (
+	"expvar"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// startTime is reported as the uptime of the debug stats
+var startTime = time.Now()
+
+// DebugConfig configures RegisterDebug
+type DebugConfig struct {
+	// Prefix of the debug routes, default "/debug"
+	Prefix string
+	// Auth protects the debug routes, default only loopback clients are allowed
+	Auth HandlerFunc
+}
+
+// RegisterDebug register the profiling routes on g:
+//
+//	/debug/pprof/    net/http/pprof profiles
+//	/debug/vars      expvar variables
+//	/debug/stats     goroutines, GC and memory stats as json
+//
+// so no second listener on http.DefaultServeMux is needed:
+//
+//	gee.RegisterDebug(r.RouterGroup, gee.DebugConfig{Auth: gee.BasicAuth(accounts)})
+func RegisterDebug(g *RouterGroup, config DebugConfig) *RouterGroup {
+	if config.Prefix == "" {
+		config.Prefix = "/debug"
+	}
+	if config.Auth == nil {
+		config.Auth = loopbackOnly()
+	}
+	debug := g.Group(g.prefix + config.Prefix)
+	debug.Use(config.Auth)
+	first := len(g.engine.routes)
+	defer func() {
+		// 调试路由不出现在 OpenAPI 文档中
+		for _, route := range g.engine.routes[first:] {
+			route.Meta.Hidden = true
+		}
+	}()
+
+	debug.GET("/pprof/", WrapF(pprof.Index))
+	debug.GET("/pprof/cmdline", WrapF(pprof.Cmdline))
+	debug.GET("/pprof/profile", WrapF(pprof.Profile))
+	debug.GET("/pprof/symbol", WrapF(pprof.Symbol))
+	debug.POST("/pprof/symbol", WrapF(pprof.Symbol))
+	debug.GET("/pprof/trace", WrapF(pprof.Trace))
+	// pprof.Index 只识别 /debug/pprof/ 下的路径，具名 profile 单独注册
+	debug.GET("/pprof/:name", func(c *Context) {
+		pprof.Handler(c.Param("name")).ServeHTTP(c.Writer, c.Req)
+	})
+	debug.GET("/vars", WrapH(expvar.Handler()))
+	debug.GET("/stats", func(c *Context) {
+		c.JSON(http.StatusOK, runtimeStats())
+	})
+	return debug
+}
+
+// loopbackOnly rejects the clients not connecting from the loopback interface,
+// the client behind a trusted proxy on the same host is checked with ClientIP
+func loopbackOnly() HandlerFunc {
+	return func(c *Context) {
+		if ip := net.ParseIP(c.ClientIP()); ip == nil || !ip.IsLoopback() {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// runtimeStats returns the state of the runtime, sizes in bytes and durations in nanoseconds
+func runtimeStats() H {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	var lastGC time.Time
+	if m.LastGC > 0 {
+		lastGC = time.Unix(0, int64(m.LastGC))
+	}
+	return H{
+		"go_version": runtime.Version(),
+		"num_cpu":    runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"goroutines": runtime.NumGoroutine(),
+		"uptime":     time.Since(startTime).String(),
+		"memory": H{
+			"alloc":         m.Alloc,
+			"total_alloc":   m.TotalAlloc,
+			"sys":           m.Sys,
+			"heap_alloc":    m.HeapAlloc,
+			"heap_inuse":    m.HeapInuse,
+			"heap_idle":     m.HeapIdle,
+			"heap_released": m.HeapReleased,
+			"heap_objects":  m.HeapObjects,
+			"stack_inuse":   m.StackInuse,
+			"mallocs":       m.Mallocs,
+			"frees":         m.Frees,
+		},
+		"gc": H{
+			"num_gc":          m.NumGC,
+			"num_forced_gc":   m.NumForcedGC,
+			"pause_total_ns":  m.PauseTotalNs,
+			"last_pause_ns":   m.PauseNs[(m.NumGC+255)%256],
+			"last_gc":         lastGC,
+			"next_gc":         m.NextGC,
+			"gc_cpu_fraction": m.GCCPUFraction,
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package gee_test
+
+import (
+	"encoding/base64"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRegisterDebug(t *testing.T) {
+	r := gee.New()
+	gee.RegisterDebug(r.RouterGroup, gee.DebugConfig{
+		Prefix: "/_debug",
+		Auth:   gee.BasicAuth(gee.Accounts{"admin": "secret"}),
+	})
+	client := geetest.New(t, r)
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("admin:secret"))
+
+	client.GET("/_debug/stats").Do().ExpectStatus(http.StatusUnauthorized)
+	res := client.GET("/_debug/stats").Header("Authorization", auth).Do().ExpectStatus(http.StatusOK)
+	var stats struct {
+		Goroutines int `json:"goroutines"`
+		Memory     struct {
+			HeapAlloc uint64 `json:"heap_alloc"`
+		} `json:"memory"`
+	}
+	if err := res.JSON(&stats); err != nil || stats.Goroutines == 0 || stats.Memory.HeapAlloc == 0 {
+		t.Errorf("stats = %+v, err %v", stats, err)
+	}
+	client.GET("/_debug/vars").Header("Authorization", auth).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBodyContains(`"memstats"`)
+	client.GET("/_debug/pprof/").Header("Authorization", auth).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBodyContains("goroutine")
+	client.GET("/_debug/pprof/goroutine").Query("debug", "1").Header("Authorization", auth).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBodyContains("goroutine profile")
+}
+
+func TestRegisterDebugLoopbackOnly(t *testing.T) {
+	r := gee.New()
+	gee.RegisterDebug(r.RouterGroup, gee.DebugConfig{})
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/stats", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("remote client: status = %d, want 403", w.Code)
+	}
+	req.RemoteAddr = "127.0.0.1:5000"
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("loopback client: status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,194 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// ErrorType is a bit set describing who is allowed to see an error
+type ErrorType uint8
+
+const (
+	// ErrorTypePrivate errors are logged but never shown to the client
+	ErrorTypePrivate ErrorType = 1 << iota
+	// ErrorTypePublic errors are shown to the client
+	ErrorTypePublic
+	// ErrorTypeAny matches every error type
+	ErrorTypeAny = ErrorTypePrivate | ErrorTypePublic
+)
+
+// Error is an error collected on the Context by c.Error
+type Error struct {
+	Err    error
+	Type   ErrorType
+	Status int // http status, 0 means let the ErrorHandler decide
+	Meta   H
+}
+
+var _ error = (*Error)(nil)
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SetType set error type
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+// SetStatus set the http status reported for the error
+func (e *Error) SetStatus(code int) *Error {
+	e.Status = code
+	return e
+}
+
+// SetMeta add a metadata entry to the error
+func (e *Error) SetMeta(key string, value interface{}) *Error {
+	if e.Meta == nil {
+		e.Meta = H{}
+	}
+	e.Meta[key] = value
+	return e
+}
+
+// IsType reports whether the error matches one of the types in flags
+func (e *Error) IsType(flags ErrorType) bool {
+	return e.Type&flags > 0
+}
+
+// errorMsgs is the list of errors collected on a Context
+type errorMsgs []*Error
+
+// ByType returns the errors matching the given types
+func (msgs errorMsgs) ByType(flags ErrorType) errorMsgs {
+	if len(msgs) == 0 {
+		return nil
+	}
+	var result errorMsgs
+	for _, e := range msgs {
+		if e.IsType(flags) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Last returns the last error, or nil
+func (msgs errorMsgs) Last() *Error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	return msgs[len(msgs)-1]
+}
+
+// Errors returns the messages of all errors
+func (msgs errorMsgs) Errors() []string {
+	messages := make([]string, 0, len(msgs))
+	for _, e := range msgs {
+		messages = append(messages, e.Error())
+	}
+	return messages
+}
+
+func (msgs errorMsgs) String() string {
+	return strings.Join(msgs.Errors(), "; ")
+}
+
+// Problem is a RFC 7807 problem details object
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Errors   []H    `json:"errors,omitempty"`
+}
+
+// ErrorHandler middleware turns the errors collected by c.Error
+// into an application/problem+json response after the chain finishes
+func ErrorHandler() HandlerFunc {
+	return func(c *Context) {
+		c.Next()
+		c.bodyError()
+		if len(c.Errors) == 0 {
+			return
+		}
+		if private := c.Errors.ByType(ErrorTypePrivate); len(private) > 0 {
+			log.Printf("[%s] %s private errors: %s", c.Method, c.Path, private)
+		}
+		// 已经有响应写出的话，只记录日志
+		if c.Written() {
+			return
+		}
+		c.Problem(problemOf(c))
+	}
+}
+
+// problemOf build the problem details from the collected errors
+func problemOf(c *Context) *Problem {
+	status := http.StatusInternalServerError
+	for _, e := range c.Errors {
+		if e.Status != 0 {
+			status = e.Status
+		}
+	}
+	p := &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: c.Path,
+	}
+	public := c.Errors.ByType(ErrorTypePublic)
+	if len(public) == 0 {
+		return p
+	}
+	p.Detail = public.Last().Error()
+	for _, e := range public {
+		item := H{"detail": e.Error()}
+		for k, v := range e.Meta {
+			item[k] = v
+		}
+		p.Errors = append(p.Errors, item)
+	}
+	return p
+}
+
+// Problem write a RFC 7807 problem details response
+func (c *Context) Problem(p *Problem) {
+	c.SetHeader("Content-Type", "application/problem+json")
+	c.Status(p.Status)
+	if err := json.NewEncoder(c.Writer).Encode(p); err != nil {
+		log.Printf("problem encode error: %v", err)
+	}
+}
+
+// HandlerFuncE is a handler that returns an error instead of writing it
+type HandlerFuncE func(c *Context) error
+
+// E adapt a HandlerFuncE to HandlerFunc, a returned error is collected by c.Error
+func E(handler HandlerFuncE) HandlerFunc {
+	return func(c *Context) {
+		if err := handler(c); err != nil {
+			c.Error(err)
+		}
+	}
+}
+
+// asError wrap err into *Error, keeping it as is when it already is one
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Err: err, Type: ErrorTypePrivate}
+}
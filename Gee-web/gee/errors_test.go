@@ -0,0 +1,96 @@
+package gee_test
+
+import (
+	"encoding/json"
+	"errors"
+	"gee"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func serve(r *gee.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestErrorHandler(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.ErrorHandler())
+	r.GET("/private", func(c *gee.Context) {
+		c.Error(errors.New("db password wrong"))
+	})
+	r.GET("/public", func(c *gee.Context) {
+		c.Error(errors.New("name is required")).
+			SetType(gee.ErrorTypePublic).
+			SetStatus(http.StatusBadRequest).
+			SetMeta("field", "name")
+	})
+	r.GET("/e", gee.E(func(c *gee.Context) error {
+		return errors.New("returned")
+	}))
+	r.GET("/written", func(c *gee.Context) {
+		c.String(http.StatusOK, "ok")
+		c.Error(errors.New("after write"))
+	})
+
+	// 私有错误不返回给客户端
+	w := serve(r, http.MethodGet, "/private")
+	var p gee.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Type") != "application/problem+json" ||
+		p.Status != http.StatusInternalServerError || p.Detail != "" || p.Instance != "/private" {
+		t.Errorf("private: %d %s", w.Code, w.Body)
+	}
+
+	w = serve(r, http.MethodGet, "/public")
+	p = gee.Problem{}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || p.Detail != "name is required" ||
+		len(p.Errors) != 1 || p.Errors[0]["field"] != "name" {
+		t.Errorf("public: %d %s", w.Code, w.Body)
+	}
+
+	if w = serve(r, http.MethodGet, "/e"); w.Code != http.StatusInternalServerError {
+		t.Errorf("E: %d %s", w.Code, w.Body)
+	}
+	// 已经写出的响应不被覆盖
+	if w = serve(r, http.MethodGet, "/written"); w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("written: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestAbortWithError(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.ErrorHandler())
+	var after bool
+	r.Use(func(c *gee.Context) {
+		c.AbortWithError(http.StatusUnauthorized, errors.New("no token")).SetType(gee.ErrorTypePublic)
+	})
+	r.GET("/", func(c *gee.Context) {
+		after = true
+	})
+	w := serve(r, http.MethodGet, "/")
+	if w.Code != http.StatusUnauthorized || after {
+		t.Errorf("status %d, handler called %v", w.Code, after)
+	}
+
+	c := &gee.Context{}
+	err := errors.New("once")
+	first := c.Error(err)
+	if c.Error(first) != first || len(c.Errors) != 1 || c.Errors.Last() != first {
+		t.Errorf("errors = %v", c.Errors)
+	}
+	c.Error(errors.New("public")).SetType(gee.ErrorTypePublic)
+	if got := c.Errors.ByType(gee.ErrorTypePublic).String(); got != "public" {
+		t.Errorf("public errors = %q", got)
+	}
+	if !errors.Is(first, err) {
+		t.Error("Error doesn't unwrap")
+	}
+}
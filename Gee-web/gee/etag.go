@@ -0,0 +1,165 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagConfig configures the ETag middleware
+type ETagConfig struct {
+	// Weak computes weak ETags, for responses whose bytes may change
+	// without changing their meaning, eg. compressed responses
+	Weak bool
+	// Skipper skips buffering when it returns true, eg. for streamed responses
+	Skipper func(c *Context) bool
+}
+
+// ETag middleware buffers the GET and HEAD responses, sets an ETag computed
+// from the body when the handler didn't set a validator and answers
+// 304 Not Modified to the matching If-None-Match and If-Modified-Since
+func ETag() HandlerFunc {
+	return ETagWithConfig(ETagConfig{})
+}
+
+// ETagWithConfig returns an ETag middleware with config
+func ETagWithConfig(config ETagConfig) HandlerFunc {
+	return func(c *Context) {
+		if (c.Method != http.MethodGet && c.Method != http.MethodHead) ||
+			(config.Skipper != nil && config.Skipper(c)) {
+			c.Next()
+			return
+		}
+		bw := c.nextBuffered()
+		body := bw.buf.Bytes()
+		if bw.code != http.StatusOK {
+			bw.flushTo(c, body)
+			return
+		}
+		if bw.header.Get("ETag") == "" {
+			sum := sha256.Sum256(body)
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			if config.Weak {
+				etag = "W/" + etag
+			}
+			bw.header.Set("ETag", etag)
+		}
+		lastModified, _ := http.ParseTime(bw.header.Get("Last-Modified"))
+		if code := checkPreconditions(c.Req, bw.header.Get("ETag"), lastModified); code != 0 {
+			writeNotModified(bw.header)
+			bw.code = code
+			body = nil
+		}
+		bw.flushTo(c, body)
+	}
+}
+
+// SetETag set the ETag of the response and checks the preconditions of the request.
+// It returns false when the handler must stop, the response is then 304 Not Modified,
+// or 412 Precondition Failed for a failed If-Match or an unsafe method:
+//
+//	if !c.SetETag(fmt.Sprintf("%d", user.Version)) {
+//		return
+//	}
+//
+// A tag without quotes is quoted, W/ marks a weak tag
+func (c *Context) SetETag(etag string) bool {
+	c.SetHeader("ETag", quoteETag(etag))
+	return c.checkPreconditions()
+}
+
+// SetLastModified set the Last-Modified of the response and checks the
+// preconditions of the request like SetETag. An If-Match fails without an
+// ETag, so it must be called after SetETag when the response has both
+func (c *Context) SetLastModified(t time.Time) bool {
+	c.SetHeader("Last-Modified", t.UTC().Format(http.TimeFormat))
+	return c.checkPreconditions()
+}
+
+func (c *Context) checkPreconditions() bool {
+	header := c.Writer.Header()
+	lastModified, _ := http.ParseTime(header.Get("Last-Modified"))
+	code := checkPreconditions(c.Req, header.Get("ETag"), lastModified)
+	switch code {
+	case 0:
+		return true
+	case http.StatusNotModified:
+		writeNotModified(header)
+	}
+	c.Status(code)
+	c.Abort()
+	return false
+}
+
+// checkPreconditions evaluate the conditional headers of req in the order of
+// RFC 9110 13.2.2 against the validators known so far, 0 means proceed
+func checkPreconditions(req *http.Request, etag string, lastModified time.Time) int {
+	safe := req.Method == http.MethodGet || req.Method == http.MethodHead
+	if im := req.Header.Get("If-Match"); im != "" {
+		// 没有当前的 ETag 时，* 和具体的 tag 都不匹配
+		if etag == "" || !etagMatch(im, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if ius, err := http.ParseTime(req.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(ius) {
+			return http.StatusPreconditionFailed
+		}
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		if etag != "" && etagMatch(inm, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if ims, err := http.ParseTime(req.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(ims) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// etagMatch reports whether etag is in the list of header, * matches any.
+// The weak comparison ignores the W/ prefix, the strong one never matches weak tags
+func etagMatch(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(tag, "W/") && !strings.HasPrefix(etag, "W/") && tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// quoteETag quote a bare tag, W/ is kept
+func quoteETag(etag string) string {
+	weak := strings.HasPrefix(etag, "W/")
+	tag := strings.TrimPrefix(etag, "W/")
+	if !strings.HasPrefix(tag, `"`) {
+		tag = `"` + tag + `"`
+	}
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// writeNotModified drop the representation headers of a 304 response
+func writeNotModified(header http.Header) {
+	header.Del("Content-Type")
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	if header.Get("ETag") != "" {
+		header.Del("Last-Modified")
+	}
+}
@@ -0,0 +1,109 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.ETag())
+	r.GET("/users", func(c *gee.Context) {
+		c.JSON(http.StatusOK, gee.H{"users": []string{"tom"}})
+	})
+	r.GET("/missing", func(c *gee.Context) {
+		c.String(http.StatusNotFound, "missing")
+	})
+	client := geetest.New(t, r)
+
+	res := client.GET("/users").Do().ExpectStatus(http.StatusOK).ExpectBody("{\"users\":[\"tom\"]}\n")
+	etag := res.Header().Get("ETag")
+	if len(etag) != 34 || etag[0] != '"' {
+		t.Fatalf("ETag = %q", etag)
+	}
+	client.GET("/users").Do().ExpectHeader("ETag", etag)
+	client.GET("/users").Header("If-None-Match", `"other", `+etag).Do().
+		ExpectStatus(http.StatusNotModified).
+		ExpectHeader("ETag", etag).
+		ExpectHeader("Content-Type", "").
+		ExpectBody("")
+	client.GET("/users").Header("If-None-Match", "W/"+etag).Do().ExpectStatus(http.StatusNotModified)
+	client.GET("/users").Header("If-None-Match", `"other"`).Do().ExpectStatus(http.StatusOK)
+	client.GET("/missing").Header("If-None-Match", "*").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectHeader("ETag", "")
+}
+
+func TestETagWeak(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.ETagWithConfig(gee.ETagConfig{Weak: true}))
+	r.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "hello") })
+	etag := geetest.New(t, r).GET("/").Do().Header().Get("ETag")
+	if etag[:3] != `W/"` {
+		t.Errorf("ETag = %q, want a weak tag", etag)
+	}
+}
+
+func TestConditionalHelpers(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	version := "v1"
+	r := gee.New()
+	r.Use(gee.ETag())
+	r.GET("/doc", func(c *gee.Context) {
+		if !c.SetETag(version) || !c.SetLastModified(modified) {
+			return
+		}
+		c.String(http.StatusOK, "doc %s", version)
+	})
+	r.GET("/dated", func(c *gee.Context) {
+		if !c.SetLastModified(modified) {
+			return
+		}
+		c.String(http.StatusOK, "dated")
+	})
+	r.POST("/doc", func(c *gee.Context) {
+		if !c.SetETag(version) {
+			return
+		}
+		version = "v2"
+		c.String(http.StatusOK, "updated")
+	})
+	client := geetest.New(t, r)
+
+	client.GET("/doc").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("ETag", `"v1"`).
+		ExpectHeader("Last-Modified", "Wed, 01 May 2024 10:00:00 GMT")
+	client.GET("/doc").Header("If-None-Match", `"v1"`).Do().
+		ExpectStatus(http.StatusNotModified).
+		ExpectHeader("Last-Modified", "")
+	// If-None-Match 存在时忽略 If-Modified-Since
+	client.GET("/doc").Header("If-None-Match", `"v0"`).
+		Header("If-Modified-Since", "Wed, 01 May 2024 10:00:00 GMT").Do().
+		ExpectStatus(http.StatusOK)
+	client.GET("/dated").Header("If-Modified-Since", "Wed, 01 May 2024 10:00:00 GMT").Do().
+		ExpectStatus(http.StatusNotModified)
+	client.GET("/dated").Header("If-Modified-Since", "Wed, 01 May 2024 09:59:59 GMT").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("dated")
+	client.GET("/dated").Header("If-Unmodified-Since", "Wed, 01 May 2024 09:00:00 GMT").Do().
+		ExpectStatus(http.StatusPreconditionFailed)
+
+	// 乐观锁：If-Match 不匹配时返回 412，不执行修改
+	client.POST("/doc").Header("If-Match", `"v0"`).Do().ExpectStatus(http.StatusPreconditionFailed)
+	client.POST("/doc").Header("If-None-Match", "*").Do().ExpectStatus(http.StatusPreconditionFailed)
+	if version != "v1" {
+		t.Fatal("failed precondition modified the document")
+	}
+	client.POST("/doc").Header("If-Match", `"v1"`).Do().ExpectStatus(http.StatusOK)
+	client.POST("/doc").Header("If-Match", `"v1"`).Do().ExpectStatus(http.StatusPreconditionFailed)
+	client.POST("/doc").Header("If-Match", `W/"v2"`).Do().ExpectStatus(http.StatusPreconditionFailed)
+
+	// 没有 ETag 的资源不满足任何 If-Match
+	client.GET("/dated").Header("If-Match", "*").Do().ExpectStatus(http.StatusPreconditionFailed)
+	client.GET("/dated").Header("If-Match", `"v2"`).Do().ExpectStatus(http.StatusPreconditionFailed)
+	client.GET("/doc").Header("If-Match", "*").Do().ExpectStatus(http.StatusOK)
+}
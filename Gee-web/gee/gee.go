@@ -1,12 +1,15 @@
 package gee
 
 import (
+	"context"
 	"fmt"
 	"html/template"
-	"log"
+	"io/fs"
+	"net"
 	"net/http"
-	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -20,20 +23,75 @@ type RouterGroup struct {
 	middlewares []HandlerFunc // support middleware
 	parent      *RouterGroup  // support nesting
 	engine      *Engine       // all groups share a engine instance
+	host        *hostRoute    // nil if the group serves every host
 }
 
 // Engine implement the interface of ServeHTTP
 type Engine struct {
 	*RouterGroup
-	router        *router
-	groups        []*RouterGroup     // store all groups存储所有的group
-	htmlTemplates *template.Template // for html render
-	funcMap       template.FuncMap   // for html render
+	router     *router
+	groups     []*RouterGroup   // store all groups存储所有的group
+	htmlRender HTMLRender       // for html render
+	funcMap    template.FuncMap // for html render
+	noRoute    []HandlerFunc    // handlers for 404
+	noMethod   []HandlerFunc    // handlers for 405
+	hosts      []*hostRoute     // routers of Engine.Host
+	routes     []*Route         // registered routes, in order
+	// server started by Run, shut down by Shutdown
+	mu           sync.Mutex
+	server       *http.Server
+	shuttingDown atomic.Bool
+	// HTMLAutoReload reparse the templates on every request
+	HTMLAutoReload bool
+	// HandleMethodNotAllowed answers 405 with an Allow header when the path
+	// is registered under other methods, otherwise 404 is returned
+	HandleMethodNotAllowed bool
+	// RedirectTrailingSlash redirects /foo/ to /foo and /foo to /foo/
+	// when only the other form is registered
+	RedirectTrailingSlash bool
+	// RedirectCleanPath redirects paths with empty, . or .. segments to
+	// the cleaned path when a route matches it, eg. /v1//hello to /v1/hello,
+	// otherwise the routes match such paths with the empty segments skipped
+	RedirectCleanPath bool
+	// RedirectCaseInsensitive redirects to the registered spelling of the
+	// path when no route matches exactly, eg. /V1/Hello to /v1/hello
+	RedirectCaseInsensitive bool
+	// MaxBodyBytes bounds the request bodies, 413 is answered when a handler
+	// reads past it; BodyLimit overrides it per route. 0, the default, is no limit
+	MaxBodyBytes int64
+	// MinReadRate is the minimum average rate in bytes per second of the body
+	// uploads once MinReadRateGrace has passed, 408 is answered to slower clients
+	MinReadRate      int64
+	MinReadRateGrace time.Duration
+	// MaxHeaderBytes is the http.Server.MaxHeaderBytes of the server started by Run,
+	// net/http answers 431 above it before the request reaches the engine; servers
+	// started otherwise must set it themselves
+	MaxHeaderBytes int
+	// MaxHeaderCount bounds the number of header values, 431 is answered above it
+	MaxHeaderCount int
+	// RemoteIPHeaders are read for the client IP when the peer is a trusted proxy,
+	// in order, default X-Forwarded-For and X-Real-IP; only the headers the
+	// proxies overwrite or append to must be listed, eg. add Forwarded behind a
+	// proxy which sets it
+	RemoteIPHeaders []string
+	// trustedProxies are set by SetTrustedProxies
+	trustedProxies []*net.IPNet
+	// ShutdownDelay is waited by Shutdown before closing the listeners,
+	// so the failing readiness probe takes the instance out of the load balancer
+	ShutdownDelay time.Duration
 }
 
 // New is the constructor of gee.Engine
 func New() *Engine {
-	engine := &Engine{router: newRouter()}
+	engine := &Engine{
+		router:                 newRouter(),
+		HandleMethodNotAllowed: true,
+		RedirectTrailingSlash:  true,
+		RedirectCleanPath:      true,
+		HTMLAutoReload:         IsDebugging(),
+		MaxHeaderBytes:         DefaultMaxHeaderBytes,
+		MaxHeaderCount:         DefaultMaxHeaderCount,
+	}
 	//声明第一个group，属于engine的，也是所有group的parent
 	engine.RouterGroup = &RouterGroup{engine: engine}
 	//初始groups，并把第一个group放进去
@@ -41,35 +99,110 @@ func New() *Engine {
 	return engine
 }
 
-// Default use Logger() & Recovery middlewares
+// Default use Logger() & Recovery middlewares, the Logger is skipped in test mode
 func Default() *Engine {
+	debugPrintWARNING("Creating an Engine instance with the Logger and Recovery middleware already attached.")
 	engine := New()
-	engine.Use(Logger(), Recovery())
+	if Mode() != TestMode {
+		engine.Use(Logger())
+	}
+	engine.Use(Recovery())
 	engine.SetFuncMap(template.FuncMap{
 		"FormatAsDate": FormatAsDate,
 	})
-	engine.LoadHTMLGlob("templates/*")
+	if err := engine.LoadHTMLGlob("templates/*"); err != nil {
+		warnPrint("load templates: %v", err)
+	}
 	return engine
 }
 
 // Run run！
 func (engine *Engine) Run(addr string) (err error) {
-	return http.ListenAndServe(addr, engine)
+	debugPrintWARNING("Running in %q mode. Switch to %q mode in production: export %s=%s",
+		DebugMode, ReleaseMode, EnvGeeMode, ReleaseMode)
+	debugPrint("Listening and serving HTTP on %s", addr)
+	server := &http.Server{Addr: addr, Handler: engine, MaxHeaderBytes: engine.MaxHeaderBytes}
+	engine.mu.Lock()
+	engine.server = server
+	engine.mu.Unlock()
+	return server.ListenAndServe()
+}
+
+// Shutdown gracefully stops the server started by Run: the readiness checks fail
+// at once, the listeners are closed after ShutdownDelay and the requests
+// in flight are waited until ctx is done. Run returns http.ErrServerClosed
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	engine.shuttingDown.Store(true)
+	if engine.ShutdownDelay > 0 {
+		debugPrint("Shutting down in %v", engine.ShutdownDelay)
+		timer := time.NewTimer(engine.ShutdownDelay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	engine.mu.Lock()
+	server := engine.server
+	engine.mu.Unlock()
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
+}
+
+// IsShuttingDown reports whether Shutdown has been called
+func (engine *Engine) IsShuttingDown() bool {
+	return engine.shuttingDown.Load()
 }
 
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// 一个请求来了之后，先判断请求和所有的group中哪些符合
 	// 就把group中注册的中间件给当前context去处理请求
+	// host 分组的中间件只在 host 匹配时生效
+	host, hostParams := engine.matchHost(req.Host)
 	var middlewares []HandlerFunc
 	for _, group := range engine.groups {
+		if group.host != nil && group.host != host {
+			continue
+		}
 		if strings.HasPrefix(req.URL.Path, group.prefix) {
 			middlewares = append(middlewares, group.middlewares...)
 		}
 	}
-	c := newContext(w, req)
-	c.engine = engine
+	c := engine.NewContext(w, req)
+	if !engine.checkHeaders(req) {
+		c.String(http.StatusRequestHeaderFieldsTooLarge, "431 REQUEST HEADER FIELDS TOO LARGE\n")
+		return
+	}
+	engine.limitBody(c)
+	c.hostRoute = host
+	c.Params = hostParams
 	c.handlers = middlewares
 	engine.router.handle(c)
+	if c.body != nil {
+		c.body.finish(c)
+	}
+}
+
+// NewContext create a Context bound to engine without routing the request,
+// it's mainly used to unit test handlers and middlewares
+func (engine *Engine) NewContext(w http.ResponseWriter, req *http.Request) *Context {
+	c := newContext(w, req)
+	c.engine = engine
+	return c
+}
+
+// NoRoute set the handlers used when no route matches,
+// middlewares registered with Use still run before them
+func (engine *Engine) NoRoute(handlers ...HandlerFunc) {
+	engine.noRoute = handlers
+}
+
+// NoMethod set the handlers used when the path only matches under other methods
+func (engine *Engine) NoMethod(handlers ...HandlerFunc) {
+	engine.noMethod = handlers
 }
 
 // SetFuncMap set engine funcMap
@@ -77,9 +210,44 @@ func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
 	engine.funcMap = funcMap
 }
 
-// LoadHTMLGlob set engine htmlTemplates
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+// LoadHTMLGlob load the templates matching pattern
+func (engine *Engine) LoadHTMLGlob(pattern string) error {
+	return engine.loadHTML(func() (*template.Template, error) {
+		return template.New("").Funcs(builtinFuncs).Funcs(engine.funcMap).ParseGlob(pattern)
+	})
+}
+
+// LoadHTMLFiles load the given template files
+func (engine *Engine) LoadHTMLFiles(files ...string) error {
+	return engine.loadHTML(func() (*template.Template, error) {
+		return template.New("").Funcs(builtinFuncs).Funcs(engine.funcMap).ParseFiles(files...)
+	})
+}
+
+// LoadHTMLFS load the templates of fsys matching patterns, embed.FS is supported
+func (engine *Engine) LoadHTMLFS(fsys fs.FS, patterns ...string) error {
+	return engine.loadHTML(func() (*template.Template, error) {
+		return template.New("").Funcs(builtinFuncs).Funcs(engine.funcMap).ParseFS(fsys, patterns...)
+	})
+}
+
+func (engine *Engine) loadHTML(load func() (*template.Template, error)) error {
+	set, err := newTemplateSet(load)
+	if err != nil {
+		return err
+	}
+	engine.htmlRender = set
+	return nil
+}
+
+// SetHTMLTemplate use an already parsed template set
+func (engine *Engine) SetHTMLTemplate(tmpl *template.Template) {
+	engine.htmlRender = &templateSet{tmpl: tmpl}
+}
+
+// SetHTMLRender use a custom HTMLRender, eg. a MultiTemplate
+func (engine *Engine) SetHTMLRender(render HTMLRender) {
+	engine.htmlRender = render
 }
 
 // Group is defined to create a new RouterGroup
@@ -89,6 +257,7 @@ func (g *RouterGroup) Group(prefix string) *RouterGroup {
 		prefix: prefix,
 		parent: g,        //engine内部的g，第一个声明的group
 		engine: g.engine, //通过g指向的engine，给newGroup赋值
+		host:   g.host,
 	}
 	//每一个新建的group都添加到engine中
 	g.engine.groups = append(g.engine.groups, newGroup)
@@ -96,22 +265,37 @@ func (g *RouterGroup) Group(prefix string) *RouterGroup {
 }
 
 // addRouter to add router
-func (g *RouterGroup) addRouter(method string, path string, handler HandlerFunc) {
+func (g *RouterGroup) addRouter(method string, path string, handlers ...HandlerFunc) *Route {
 	//Engine继承了RouterGroup的所有方法， (*Engine).engine指向的也是自己
 	//所有这里，不光group可以添加路由，engine自己也能
 	pattern := g.prefix + path //拼接分组前缀
-	log.Printf("Route %s - %s", method, pattern)
-	g.engine.router.addRoute(method, pattern, handler)
+	route := &Route{Method: method, Path: pattern}
+	g.engine.routes = append(g.engine.routes, route)
+	if g.host != nil {
+		route.Host = g.host.pattern
+		debugPrint("Route %s - %s%s", method, g.host.pattern, pattern)
+		g.host.router.addRoute(method, pattern, handlers...)
+		return route
+	}
+	debugPrint("Route %s - %s", method, pattern)
+	g.engine.router.addRoute(method, pattern, handlers...)
+	return route
 }
 
-// GET defines the method to add GET request
-func (g *RouterGroup) GET(pattern string, handler HandlerFunc) {
-	g.addRouter("GET", pattern, handler)
+// GET defines the method to add GET request,
+// the handlers before the last one act as route middlewares
+func (g *RouterGroup) GET(pattern string, handlers ...HandlerFunc) *Route {
+	return g.addRouter("GET", pattern, handlers...)
 }
 
 // POST defines the method to add POST request
-func (g *RouterGroup) POST(pattern string, handler HandlerFunc) {
-	g.addRouter("POST", pattern, handler)
+func (g *RouterGroup) POST(pattern string, handlers ...HandlerFunc) *Route {
+	return g.addRouter("POST", pattern, handlers...)
+}
+
+// HEAD defines the method to add HEAD request
+func (g *RouterGroup) HEAD(pattern string, handlers ...HandlerFunc) *Route {
+	return g.addRouter("HEAD", pattern, handlers...)
 }
 
 // Use register middlewares to group
@@ -119,31 +303,6 @@ func (g *RouterGroup) Use(middlewares ...HandlerFunc) {
 	g.middlewares = append(g.middlewares, middlewares...)
 }
 
-// createStaticHandler create a static handler
-func (g *RouterGroup) createStaticHandler(relativePath string, fs http.FileSystem) HandlerFunc {
-	absolutePath := path.Join(g.prefix, relativePath)
-	fileServer := http.StripPrefix(absolutePath, http.FileServer(fs))
-	return func(c *Context) {
-		file := c.Param("filepath")
-		// Check if file exists and/or if we have permission to access it
-		//log.Printf("%v", fs)
-		if _, err := fs.Open(file); err != nil {
-			//log.Printf("%s未找到", file)
-			c.Status(http.StatusNotFound)
-			return
-		}
-		fileServer.ServeHTTP(c.Writer, c.Req)
-	}
-}
-
-// Static serve static file
-func (g *RouterGroup) Static(relativePath string, root string) {
-	handler := g.createStaticHandler(relativePath, http.Dir(root))
-	pattern := path.Join(relativePath, "/*filepath")
-	// register static handler
-	g.GET(pattern, handler)
-}
-
 func FormatAsDate(t time.Time) string {
 	year, month, day := t.Date()
 	return fmt.Sprintf("%d-%02d-%02d", year, month, day)
@@ -0,0 +1,381 @@
+// Package geecachemw caches whole gee responses in a geecache Group, so the
+// consistent-hash peers share the cached pages. It's a module of its own,
+// the gee framework doesn't depend on geecache
+package geecachemw
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"gee"
+	"geecache"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errNotCached is returned by the getter of the group, the responses are only
+// stored by the requests rendering them
+var errNotCached = errors.New("geecachemw: response is not cached")
+
+// maxUncacheable bounds the keys remembered as not cacheable
+const maxUncacheable = 10000
+
+// cacheableStatus are the status codes cached by Cache
+var cacheableStatus = map[int]bool{
+	http.StatusOK:                   true,
+	http.StatusNonAuthoritativeInfo: true,
+	http.StatusNoContent:            true,
+	http.StatusMovedPermanently:     true,
+	http.StatusNotFound:             true,
+	http.StatusGone:                 true,
+}
+
+// uncachedHeaders belong to the request which rendered the response, they aren't stored
+var uncachedHeaders = []string{gee.HeaderTraceParent, gee.HeaderTraceState, "Connection"}
+
+// Config configures New
+type Config struct {
+	// Name of the geecache group, default "gee-responses"; the peers
+	// share the entries of the groups with the same name
+	Name string
+	// CacheBytes bounds the entries kept by this instance, default 64MB
+	CacheBytes int64
+	// Vary lists the request headers the responses depend on, their values are
+	// part of the key; a response varying on other headers isn't cached
+	Vary []string
+	// IgnoreQuery lists the query params left out of the key, eg. utm_source
+	IgnoreQuery []string
+	// DefaultTTL caches the responses without s-maxage, max-age or Expires,
+	// 0 doesn't cache them
+	DefaultTTL time.Duration
+	// NegativeTTL remembers the keys of the uncacheable responses, they go
+	// straight to the handlers meanwhile, default 10 seconds
+	NegativeTTL time.Duration
+	// Skipper bypasses the cache when it returns true
+	Skipper func(c *gee.Context) bool
+	// KeyFunc identifies the response of a request, an empty key bypasses the
+	// cache. Default the host, the path, the sorted query without IgnoreQuery
+	// and the Vary headers, HEAD shares the key of GET
+	KeyFunc func(c *gee.Context) string
+}
+
+// Cache caches whole GET responses in a geecache Group:
+//
+//	cache := geecachemw.New(r, geecachemw.Config{Vary: []string{"Accept-Language"}})
+//	pool := geecache.NewHTTPPool("http://10.0.0.1:9999")
+//	pool.Set(peers...)
+//	pool.Transport = gee.TraceTransport{}
+//	cache.Group().RegisterPeers(pool)
+//	r.GET("/_geecache/*key", gee.WrapH(pool))
+//	r.GET("/articles/:id", cache.Middleware(), article)
+//
+// A miss renders the request itself and stores the response in the group. A key
+// owned by a peer is fetched from it, the owner serves the responses it stored
+// for its own requests; the requests with a Cookie or an Authorization header
+// bypass the cache, so the routes must not depend on other headers than the Vary ones
+type Cache struct {
+	engine *gee.Engine
+	config Config
+	vary   map[string]bool
+	group  *geecache.Group
+
+	mu sync.Mutex
+	// rendering holds the keys rendered by a request, closed once stored
+	rendering   map[string]chan struct{}
+	uncacheable map[string]time.Time
+}
+
+// entry is the gob encoded value of a cached response
+type entry struct {
+	Status  int
+	Header  http.Header
+	Body    []byte
+	Date    time.Time
+	Expires time.Time
+}
+
+// New create a Cache of the responses of engine
+func New(engine *gee.Engine, config Config) *Cache {
+	if config.Name == "" {
+		config.Name = "gee-responses"
+	}
+	if config.CacheBytes <= 0 {
+		config.CacheBytes = 64 << 20
+	}
+	if config.NegativeTTL <= 0 {
+		config.NegativeTTL = 10 * time.Second
+	}
+	rc := &Cache{
+		engine:      engine,
+		config:      config,
+		vary:        make(map[string]bool),
+		rendering:   make(map[string]chan struct{}),
+		uncacheable: make(map[string]time.Time),
+	}
+	rc.config.Vary = make([]string, len(config.Vary))
+	for i, name := range config.Vary {
+		rc.config.Vary[i] = http.CanonicalHeaderKey(name)
+		rc.vary[rc.config.Vary[i]] = true
+	}
+	if rc.config.KeyFunc == nil {
+		rc.config.KeyFunc = func(c *gee.Context) string {
+			return rc.key(c.Req)
+		}
+	}
+	rc.group = geecache.NewGroup(config.Name, config.CacheBytes, geecache.GetterFunc(func(string) ([]byte, error) {
+		return nil, errNotCached
+	}))
+	return rc
+}
+
+// Group returns the geecache Group of the responses, to register the peers
+func (rc *Cache) Group() *geecache.Group {
+	return rc.group
+}
+
+// Middleware serves the cached responses, X-Cache is HIT when the response
+// comes from the cache and MISS when it was just stored
+func (rc *Cache) Middleware() gee.HandlerFunc {
+	return func(c *gee.Context) {
+		if (c.Method != http.MethodGet && c.Method != http.MethodHead) ||
+			c.Req.Header.Get("Authorization") != "" || c.Req.Header.Get("Cookie") != "" ||
+			(rc.config.Skipper != nil && rc.config.Skipper(c)) {
+			c.Next()
+			return
+		}
+		directives := parseCacheControl(c.Req.Header.Get("Cache-Control"))
+		if _, ok := directives["no-store"]; ok {
+			c.Next()
+			return
+		}
+		if _, ok := directives["no-cache"]; ok {
+			c.Next()
+			return
+		}
+		key := rc.config.KeyFunc(c)
+		if key == "" || rc.isUncacheable(key) {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		if rc.serve(c, key, directives, start) {
+			c.Abort()
+			return
+		}
+		// HEAD 没有 body，不能存入
+		if c.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		// 同一个 key 只由一个请求渲染，其余的等它存入后再读
+		rc.mu.Lock()
+		done, waiting := rc.rendering[key]
+		if !waiting {
+			done = make(chan struct{})
+			rc.rendering[key] = done
+		}
+		rc.mu.Unlock()
+		if waiting {
+			select {
+			case <-done:
+			case <-c.Req.Context().Done():
+			}
+			if rc.serve(c, key, directives, start) {
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		defer func() {
+			rc.mu.Lock()
+			delete(rc.rendering, key)
+			rc.mu.Unlock()
+			close(done)
+		}()
+
+		// handler 在请求自己的 goroutine 中运行，Recovery 和 trace 照常生效
+		res := c.NextBuffered()
+		if rc.store(key, res) {
+			res.Header.Set("X-Cache", "MISS")
+		}
+		c.WriteBuffered(res)
+		c.Abort()
+	}
+}
+
+// serve write the cached response of key, it returns false when there is none.
+// An entry older than the max-age of the request is bypassed: the chain runs
+// without storing its response
+func (rc *Cache) serve(c *gee.Context, key string, directives map[string]string, start time.Time) bool {
+	view, err := rc.group.GetContext(c.Req.Context(), key)
+	if err != nil {
+		if !errors.Is(err, errNotCached) {
+			log.Printf("[geecachemw] %v", err)
+		}
+		return false
+	}
+	var e entry
+	if err := gob.NewDecoder(bytes.NewReader(view.ByteSlice())).Decode(&e); err != nil {
+		log.Printf("[geecachemw] decode entry: %v", err)
+		return false
+	}
+	// 从节点取回的值不带过期时间，以 entry 中的为准
+	now := time.Now()
+	if !now.Before(e.Expires) {
+		return false
+	}
+	age := now.Sub(e.Date)
+	if directives["max-age"] != "" && age > maxAge(directives["max-age"]) {
+		c.Next()
+		return true
+	}
+
+	header := c.Writer.Header()
+	for k, v := range e.Header {
+		header[k] = v
+	}
+	header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	if e.Date.Before(start) {
+		header.Set("X-Cache", "HIT")
+	} else {
+		header.Set("X-Cache", "MISS")
+	}
+	c.Status(e.Status)
+	c.Writer.Write(e.Body)
+	return true
+}
+
+// key identifies the response of req: the method, the host, the path,
+// the sorted query without the ignored params and the Vary headers
+func (rc *Cache) key(req *http.Request) string {
+	query := req.URL.Query()
+	for _, name := range rc.config.IgnoreQuery {
+		query.Del(name)
+	}
+	var b strings.Builder
+	b.WriteString(http.MethodGet + " " + req.Host + req.URL.EscapedPath())
+	if encoded := query.Encode(); encoded != "" {
+		b.WriteString("?" + encoded)
+	}
+	for _, name := range rc.config.Vary {
+		b.WriteString("\n" + name + ": " + strings.Join(req.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+// store the response of key in the group when a shared cache may store it,
+// otherwise key is remembered as uncacheable for NegativeTTL
+func (rc *Cache) store(key string, res *gee.BufferedResponse) bool {
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+	now := time.Now()
+	ttl := rc.freshness(res.Status, res.Header, now)
+	if ttl <= 0 {
+		rc.setUncacheable(key, now)
+		return false
+	}
+	header := res.Header.Clone()
+	for _, name := range uncachedHeaders {
+		header.Del(name)
+	}
+	e := entry{Status: res.Status, Header: header, Body: res.Body, Date: now, Expires: now.Add(ttl)}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&e); err != nil {
+		log.Printf("[geecachemw] encode entry: %v", err)
+		return false
+	}
+	rc.group.Set(key, buf.Bytes(), e.Expires)
+	return true
+}
+
+// isUncacheable reports whether key was found uncacheable within NegativeTTL
+func (rc *Cache) isUncacheable(key string) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	until, ok := rc.uncacheable[key]
+	if ok && time.Now().After(until) {
+		delete(rc.uncacheable, key)
+		return false
+	}
+	return ok
+}
+
+func (rc *Cache) setUncacheable(key string, now time.Time) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.uncacheable) >= maxUncacheable {
+		for k, until := range rc.uncacheable {
+			if now.After(until) {
+				delete(rc.uncacheable, k)
+			}
+		}
+		if len(rc.uncacheable) >= maxUncacheable {
+			return
+		}
+	}
+	rc.uncacheable[key] = now.Add(rc.config.NegativeTTL)
+}
+
+// freshness returns how long a shared cache may keep the response, 0 when it mustn't store it
+func (rc *Cache) freshness(code int, header http.Header, now time.Time) time.Duration {
+	if !cacheableStatus[code] || header.Get("Set-Cookie") != "" {
+		return 0
+	}
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && !rc.vary[name] {
+				return 0
+			}
+		}
+	}
+	directives := parseCacheControl(header.Get("Cache-Control"))
+	for _, name := range []string{"no-store", "no-cache", "private"} {
+		if _, ok := directives[name]; ok {
+			return 0
+		}
+	}
+	if v, ok := directives["s-maxage"]; ok {
+		return maxAge(v)
+	}
+	if v, ok := directives["max-age"]; ok {
+		return maxAge(v)
+	}
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return 0
+		}
+		return expires.Sub(now)
+	}
+	return rc.config.DefaultTTL
+}
+
+// parseCacheControl parse the directives of a Cache-Control header,
+// the names are lower case and the directives without value map to ""
+func parseCacheControl(header string) map[string]string {
+	directives := make(map[string]string)
+	for _, part := range strings.Split(header, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if name == "" {
+			continue
+		}
+		directives[strings.ToLower(name)] = strings.Trim(value, `"`)
+	}
+	return directives
+}
+
+// maxAge parse a delta-seconds value, an invalid one is 0
+func maxAge(v string) time.Duration {
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
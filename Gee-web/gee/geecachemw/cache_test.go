@@ -0,0 +1,342 @@
+package geecachemw_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gee"
+	"gee/geecachemw"
+	"gee/geetest"
+	"geecache"
+	pb "geecache/geecachepb"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	renders := 0
+	r := gee.New()
+	cache := geecachemw.New(r, geecachemw.Config{
+		Name:        "TestResponseCache",
+		Vary:        []string{"accept-language"},
+		IgnoreQuery: []string{"utm_source"},
+	})
+	r.Use(cache.Middleware())
+	r.GET("/articles/:id", func(c *gee.Context) {
+		renders++
+		c.SetHeader("Cache-Control", "public, max-age=60")
+		c.SetHeader("Vary", "Accept-Language")
+		c.String(http.StatusOK, "article %s %s %s", c.Param("id"), c.Query("page"), c.Req.Header.Get("Accept-Language"))
+	})
+	client := geetest.New(t, r)
+
+	client.GET("/articles/1?page=2&utm_source=mail").Header("Accept-Language", "en").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Cache", "MISS").
+		ExpectHeader("Cache-Control", "public, max-age=60").
+		ExpectBody("article 1 2 en")
+	client.GET("/articles/1?utm_source=feed&page=2").Header("Accept-Language", "en").Do().
+		ExpectHeader("X-Cache", "HIT").
+		ExpectHeader("Age", "0").
+		ExpectBody("article 1 2 en")
+	client.HEAD("/articles/1?page=2").Header("Accept-Language", "en").Do().ExpectHeader("X-Cache", "HIT")
+	if renders != 1 {
+		t.Fatalf("renders = %d, want 1", renders)
+	}
+
+	// Vary 的头和 query 都是 key 的一部分
+	client.GET("/articles/1?page=2").Header("Accept-Language", "fr").Do().
+		ExpectHeader("X-Cache", "MISS").
+		ExpectBody("article 1 2 fr")
+	client.GET("/articles/1?page=3").Header("Accept-Language", "en").Do().ExpectHeader("X-Cache", "MISS")
+	if renders != 3 {
+		t.Fatalf("renders = %d, want 3", renders)
+	}
+
+	// 请求的 Cache-Control 和 Authorization 绕过缓存
+	client.GET("/articles/1?page=2").Header("Accept-Language", "en").Header("Cache-Control", "no-cache").Do().
+		ExpectHeader("X-Cache", "")
+	client.GET("/articles/1?page=2").Header("Accept-Language", "en").Header("Cache-Control", "max-age=0").Do().
+		ExpectHeader("X-Cache", "")
+	client.GET("/articles/1?page=2").Header("Accept-Language", "en").Header("Authorization", "Bearer x").Do().
+		ExpectHeader("X-Cache", "")
+	client.GET("/articles/1?page=2").Header("Accept-Language", "en").Header("Cookie", "session=1").Do().
+		ExpectHeader("X-Cache", "")
+	if renders != 7 {
+		t.Fatalf("renders = %d, want 7", renders)
+	}
+}
+
+func TestCacheUncacheable(t *testing.T) {
+	r := gee.New()
+	cache := geecachemw.New(r, geecachemw.Config{Name: "TestResponseCacheUncacheable"})
+	r.Use(cache.Middleware())
+	r.GET("/private", func(c *gee.Context) {
+		c.SetHeader("Cache-Control", "private, max-age=60")
+		c.String(http.StatusOK, "private")
+	})
+	r.GET("/cookie", func(c *gee.Context) {
+		c.SetHeader("Cache-Control", "max-age=60")
+		http.SetCookie(c.Writer, &http.Cookie{Name: "session", Value: "1"})
+		c.String(http.StatusOK, "cookie")
+	})
+	r.GET("/vary", func(c *gee.Context) {
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.SetHeader("Vary", "Cookie")
+		c.String(http.StatusOK, "vary")
+	})
+	r.GET("/error", func(c *gee.Context) {
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.String(http.StatusInternalServerError, "error")
+	})
+	r.GET("/fresh", func(c *gee.Context) {
+		c.String(http.StatusOK, "fresh")
+	})
+	client := geetest.New(t, r)
+
+	for _, path := range []string{"/private", "/cookie", "/vary", "/error", "/fresh"} {
+		for i := 0; i < 2; i++ {
+			client.GET(path).Do().ExpectHeader("X-Cache", "").ExpectBody(path[1:])
+		}
+	}
+
+	r2 := gee.New()
+	cache = geecachemw.New(r2, geecachemw.Config{Name: "TestResponseCacheDefaultTTL", DefaultTTL: time.Minute})
+	r2.GET("/fresh", cache.Middleware(), func(c *gee.Context) {
+		c.String(http.StatusOK, "fresh")
+	})
+	client = geetest.New(t, r2)
+	client.GET("/fresh").Do().ExpectHeader("X-Cache", "MISS")
+	client.GET("/fresh").Do().ExpectHeader("X-Cache", "HIT").ExpectBody("fresh")
+}
+
+// enginePeer forwards the loads of every key to the response cache of another engine
+type enginePeer struct {
+	group *geecache.Group
+}
+
+func (p *enginePeer) PickPeer(key string) (geecache.PeerGetter, bool) {
+	return p, true
+}
+
+func (p *enginePeer) Get(in *pb.Request, out *pb.Response) error {
+	return p.GetContext(context.Background(), in, out)
+}
+
+func (p *enginePeer) GetContext(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	view, err := p.group.GetContext(ctx, in.Key)
+	if err != nil {
+		return err
+	}
+	out.Value = view.ByteSlice()
+	return nil
+}
+
+func TestCachePeers(t *testing.T) {
+	renders := map[string]int{}
+	newEngine := func(node, name string) (*gee.Engine, *geecachemw.Cache) {
+		r := gee.New()
+		cache := geecachemw.New(r, geecachemw.Config{Name: name})
+		r.GET("/page", cache.Middleware(), func(c *gee.Context) {
+			renders[node]++
+			c.SetHeader("Cache-Control", "s-maxage=1, max-age=0")
+			c.String(http.StatusOK, "rendered by %s", node)
+		})
+		return r, cache
+	}
+	owner, ownerCache := newEngine("owner", "TestResponseCachePeersOwner")
+	front, frontCache := newEngine("front", "TestResponseCachePeersFront")
+	frontCache.Group().RegisterPeers(&enginePeer{group: ownerCache.Group()})
+
+	// front 从 owner 取回 owner 存入的响应
+	geetest.New(t, owner).GET("/page").Do().ExpectHeader("X-Cache", "MISS").ExpectBody("rendered by owner")
+	geetest.New(t, front).GET("/page").Do().ExpectHeader("X-Cache", "HIT").ExpectBody("rendered by owner")
+	geetest.New(t, front).GET("/page").Do().ExpectBody("rendered by owner")
+	if renders["owner"] != 1 || renders["front"] != 0 {
+		t.Fatalf("renders = %v, want only one by the owner", renders)
+	}
+
+	// owner 上过期之后，front 自己渲染
+	time.Sleep(1100 * time.Millisecond)
+	geetest.New(t, front).GET("/page").Do().ExpectHeader("X-Cache", "MISS").ExpectBody("rendered by front")
+	geetest.New(t, front).GET("/page").Do().ExpectHeader("X-Cache", "HIT").ExpectBody("rendered by front")
+	if renders["owner"] != 1 || renders["front"] != 1 {
+		t.Fatalf("renders = %v, want one by each node", renders)
+	}
+}
+
+func TestCacheRendersUncacheableOnce(t *testing.T) {
+	renders := 0
+	r := gee.New()
+	cache := geecachemw.New(r, geecachemw.Config{Name: "TestCacheRendersUncacheableOnce", NegativeTTL: time.Hour})
+	r.GET("/counter", cache.Middleware(), func(c *gee.Context) {
+		renders++
+		c.SetHeader("Cache-Control", "no-store")
+		c.String(http.StatusOK, "render %d", renders)
+	})
+	client := geetest.New(t, r)
+
+	for i := 1; i <= 3; i++ {
+		client.GET("/counter").Do().ExpectHeader("X-Cache", "").ExpectBody(fmt.Sprintf("render %d", i))
+		if renders != i {
+			t.Fatalf("renders = %d after %d requests", renders, i)
+		}
+	}
+}
+
+func TestCachePeersUncacheable(t *testing.T) {
+	renders := map[string]int{}
+	newEngine := func(node, name string) (*gee.Engine, *geecachemw.Cache) {
+		r := gee.New()
+		cache := geecachemw.New(r, geecachemw.Config{Name: name})
+		r.GET("/me", cache.Middleware(), func(c *gee.Context) {
+			renders[node]++
+			c.SetHeader("Cache-Control", "private")
+			c.String(http.StatusOK, "rendered by %s", node)
+		})
+		return r, cache
+	}
+	_, ownerCache := newEngine("owner", "TestCachePeersUncacheableOwner")
+	front, frontCache := newEngine("front", "TestCachePeersUncacheableFront")
+	frontCache.Group().RegisterPeers(&enginePeer{group: ownerCache.Group()})
+
+	client := geetest.New(t, front)
+	for i := 0; i < 3; i++ {
+		client.GET("/me").Do().ExpectHeader("X-Cache", "").ExpectBody("rendered by front")
+	}
+	// 请求不会在 owner 上重放
+	if renders["owner"] != 0 || renders["front"] != 3 {
+		t.Fatalf("renders = %v, want 3 on the front only", renders)
+	}
+}
+
+// tracePeer records the trace of the loads and fails them
+type tracePeer struct {
+	traces []string
+}
+
+func (p *tracePeer) PickPeer(key string) (geecache.PeerGetter, bool) {
+	return p, true
+}
+
+func (p *tracePeer) Get(in *pb.Request, out *pb.Response) error {
+	return p.GetContext(context.Background(), in, out)
+}
+
+func (p *tracePeer) GetContext(ctx context.Context, in *pb.Request, out *pb.Response) error {
+	trace := ""
+	if span := gee.SpanFromContext(ctx); span != nil {
+		trace = span.SpanContext().TraceParent()
+	}
+	p.traces = append(p.traces, trace)
+	return errors.New("peer down")
+}
+
+func TestCachePeersTrace(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.Tracing(gee.NewInMemoryExporter()))
+	cache := geecachemw.New(r, geecachemw.Config{Name: "TestCachePeersTrace"})
+	peer := &tracePeer{}
+	cache.Group().RegisterPeers(peer)
+	var handlerTrace string
+	r.GET("/page", cache.Middleware(), func(c *gee.Context) {
+		if span := gee.SpanFromContext(c.Req.Context()); span != nil {
+			handlerTrace = span.SpanContext().TraceParent()
+		}
+		c.String(http.StatusOK, "page")
+	})
+	res := geetest.New(t, r).GET("/page").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("page")
+	// 请求的 span 随 context 传给了节点，handler 在同一个 span 中渲染
+	traceparent := res.Header().Get(gee.HeaderTraceParent)
+	if len(peer.traces) != 1 || peer.traces[0] == "" || peer.traces[0] != traceparent {
+		t.Errorf("traces = %q, response traceparent %q", peer.traces, traceparent)
+	}
+	if handlerTrace != traceparent {
+		t.Errorf("handler traceparent %q, want %q", handlerTrace, traceparent)
+	}
+}
+
+// 同时未命中的请求只渲染一次
+func TestCacheConcurrentMisses(t *testing.T) {
+	var renders atomic.Int32
+	r := gee.New()
+	cache := geecachemw.New(r, geecachemw.Config{Name: "TestCacheConcurrentMisses"})
+	r.GET("/slow", cache.Middleware(), func(c *gee.Context) {
+		renders.Add(1)
+		time.Sleep(50 * time.Millisecond)
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, "slow")
+	})
+	client := geetest.New(t, r)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.GET("/slow").Do().ExpectStatus(http.StatusOK).ExpectBody("slow")
+		}()
+	}
+	wg.Wait()
+	if n := renders.Load(); n != 1 {
+		t.Fatalf("renders = %d, want 1", n)
+	}
+}
+
+func TestCacheRecovery(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.Recovery())
+	cache := geecachemw.New(r, geecachemw.Config{Name: "TestCacheRecovery", NegativeTTL: time.Hour})
+	fail := true
+	r.GET("/page", cache.Middleware(), func(c *gee.Context) {
+		if fail {
+			panic("render failed")
+		}
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, "page")
+	})
+	client := geetest.New(t, r)
+
+	// panic 由请求链上的 Recovery 处理，不会被缓存
+	client.GET("/page").Do().ExpectStatus(http.StatusInternalServerError).ExpectHeader("X-Cache", "")
+	fail = false
+	client.GET("/page?v=2").Do().ExpectStatus(http.StatusOK).ExpectHeader("X-Cache", "MISS")
+	client.GET("/page?v=2").Do().ExpectStatus(http.StatusOK).ExpectHeader("X-Cache", "HIT")
+}
+
+func TestCacheKeyFunc(t *testing.T) {
+	renders := 0
+	r := gee.New()
+	cache := geecachemw.New(r, geecachemw.Config{
+		Name: "TestCacheKeyFunc",
+		// 只按 path 缓存，忽略 query；/nocache 不缓存
+		KeyFunc: func(c *gee.Context) string {
+			if c.Path == "/nocache" {
+				return ""
+			}
+			return c.Path
+		},
+	})
+	r.Use(cache.Middleware())
+	handler := func(c *gee.Context) {
+		renders++
+		c.SetHeader("Cache-Control", "max-age=60")
+		c.String(http.StatusOK, "%s %s", c.Path, c.Query("q"))
+	}
+	r.GET("/search", handler)
+	r.GET("/nocache", handler)
+	client := geetest.New(t, r)
+
+	client.GET("/search?q=a").Do().ExpectHeader("X-Cache", "MISS").ExpectBody("/search a")
+	client.GET("/search?q=b").Do().ExpectHeader("X-Cache", "HIT").ExpectBody("/search a")
+	client.GET("/nocache").Do().ExpectHeader("X-Cache", "")
+	client.GET("/nocache").Do().ExpectHeader("X-Cache", "")
+	if renders != 3 {
+		t.Fatalf("renders = %d, want 3", renders)
+	}
+}
@@ -0,0 +1,15 @@
+module gee/geecachemw
+
+go 1.20
+
+require (
+	gee v0.0.0
+	geecache v0.0.0
+)
+
+require google.golang.org/protobuf v1.34.2 // indirect
+
+replace (
+	gee => ../
+	geecache => ../../../Gee-cache/geecache
+)
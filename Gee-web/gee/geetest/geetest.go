@@ -0,0 +1,289 @@
+// Package geetest helps to unit test gee handlers and middlewares.
+//
+//	client := geetest.New(t, engine)
+//	client.POST("/login").JSON(gee.H{"name": "bob"}).Do().
+//		ExpectStatus(http.StatusOK).
+//		ExpectJSON("user.name", "bob")
+package geetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"gee"
+	"gee/internal/templatehook"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// CreateTestContext create an Engine and a Context writing to w,
+// the Context holds a GET / request which can be replaced through c.Req
+func CreateTestContext(w http.ResponseWriter) (*gee.Context, *gee.Engine) {
+	engine := gee.New()
+	c := engine.NewContext(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	return c, engine
+}
+
+// Client sends requests through an Engine without a network listener
+type Client struct {
+	t      testing.TB
+	engine *gee.Engine
+}
+
+// New create a Client for engine, the templates rendered by c.HTML are recorded
+func New(t testing.TB, engine *gee.Engine) *Client {
+	return &Client{t: t, engine: engine}
+}
+
+// Request start building a request
+func (client *Client) Request(method, path string) *RequestBuilder {
+	return &RequestBuilder{
+		client: client,
+		method: method,
+		path:   path,
+		header: make(http.Header),
+		query:  make(url.Values),
+	}
+}
+
+// GET start building a GET request
+func (client *Client) GET(path string) *RequestBuilder {
+	return client.Request(http.MethodGet, path)
+}
+
+// POST start building a POST request
+func (client *Client) POST(path string) *RequestBuilder {
+	return client.Request(http.MethodPost, path)
+}
+
+// HEAD start building a HEAD request
+func (client *Client) HEAD(path string) *RequestBuilder {
+	return client.Request(http.MethodHead, path)
+}
+
+// RequestBuilder builds a request with a fluent api
+type RequestBuilder struct {
+	client *Client
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   io.Reader
+	remote string
+	err    error
+}
+
+// Header set a request header
+func (b *RequestBuilder) Header(key, value string) *RequestBuilder {
+	b.header.Set(key, value)
+	return b
+}
+
+// RemoteAddr set the address of the client, default 192.0.2.1:1234
+func (b *RequestBuilder) RemoteAddr(addr string) *RequestBuilder {
+	b.remote = addr
+	return b
+}
+
+// Query add a query parameter
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	b.query.Add(key, value)
+	return b
+}
+
+// Body use r as the request body
+func (b *RequestBuilder) Body(r io.Reader) *RequestBuilder {
+	b.body = r
+	return b
+}
+
+// JSON encode v as the request body
+func (b *RequestBuilder) JSON(v interface{}) *RequestBuilder {
+	data, err := json.Marshal(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+	b.body = bytes.NewReader(data)
+	return b.Header("Content-Type", "application/json")
+}
+
+// Form encode values as an url encoded form body
+func (b *RequestBuilder) Form(values url.Values) *RequestBuilder {
+	b.body = strings.NewReader(values.Encode())
+	return b.Header("Content-Type", "application/x-www-form-urlencoded")
+}
+
+// Build returns the http.Request
+func (b *RequestBuilder) Build() *http.Request {
+	b.client.t.Helper()
+	if b.err != nil {
+		b.client.t.Fatalf("geetest: build request: %v", b.err)
+	}
+	target := b.path
+	if len(b.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + b.query.Encode()
+	}
+	req := httptest.NewRequest(b.method, target, b.body)
+	for key, values := range b.header {
+		req.Header[key] = values
+	}
+	// net/http 把 Host 头放在 req.Host 中
+	if host := b.header.Get("Host"); host != "" {
+		req.Host = host
+		req.Header.Del("Host")
+	}
+	if b.remote != "" {
+		req.RemoteAddr = b.remote
+	}
+	return req
+}
+
+// Do run the request through the engine
+func (b *RequestBuilder) Do() *Response {
+	b.client.t.Helper()
+	res := &Response{ResponseRecorder: httptest.NewRecorder(), t: b.client.t}
+	// 模板记录在请求自己的 context 中，并发的请求互不影响
+	var mu sync.Mutex
+	req := b.Build()
+	req = req.WithContext(templatehook.With(req.Context(), func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		res.Templates = append(res.Templates, name)
+	}))
+	b.client.engine.ServeHTTP(res, req)
+	mu.Lock()
+	defer mu.Unlock()
+	return res
+}
+
+// Response is the recorded response with fluent assertions
+type Response struct {
+	*httptest.ResponseRecorder
+	t testing.TB
+	// Templates lists the templates looked up by c.HTML
+	Templates []string
+}
+
+// ExpectStatus check the status code
+func (res *Response) ExpectStatus(code int) *Response {
+	res.t.Helper()
+	if res.Code != code {
+		res.t.Errorf("status = %d, want %d, body: %s", res.Code, code, res.Body.String())
+	}
+	return res
+}
+
+// ExpectHeader check a response header
+func (res *Response) ExpectHeader(key, value string) *Response {
+	res.t.Helper()
+	if got := res.Header().Get(key); got != value {
+		res.t.Errorf("header %s = %q, want %q", key, got, value)
+	}
+	return res
+}
+
+// ExpectBody check the whole body
+func (res *Response) ExpectBody(body string) *Response {
+	res.t.Helper()
+	if got := res.Body.String(); got != body {
+		res.t.Errorf("body = %q, want %q", got, body)
+	}
+	return res
+}
+
+// ExpectBodyContains check the body contains s
+func (res *Response) ExpectBodyContains(s string) *Response {
+	res.t.Helper()
+	if got := res.Body.String(); !strings.Contains(got, s) {
+		res.t.Errorf("body = %q, want it to contain %q", got, s)
+	}
+	return res
+}
+
+// ExpectJSON check the value at a dot separated path of the json body,
+// array elements are addressed by index, eg. "users.0.name".
+// An empty path compares the whole body
+func (res *Response) ExpectJSON(path string, want interface{}) *Response {
+	res.t.Helper()
+	var body interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &body); err != nil {
+		res.t.Errorf("body is not json: %v, body: %s", err, res.Body.String())
+		return res
+	}
+	got, ok := lookupJSON(body, path)
+	if !ok {
+		res.t.Errorf("json path %q not found in %s", path, res.Body.String())
+		return res
+	}
+	if normalized := normalizeJSON(want); !reflect.DeepEqual(got, normalized) {
+		res.t.Errorf("json path %q = %#v, want %#v", path, got, normalized)
+	}
+	return res
+}
+
+// ExpectTemplate check the template name was rendered
+func (res *Response) ExpectTemplate(name string) *Response {
+	res.t.Helper()
+	for _, tmpl := range res.Templates {
+		if tmpl == name {
+			return res
+		}
+	}
+	res.t.Errorf("template %q not rendered, rendered: %v", name, res.Templates)
+	return res
+}
+
+// JSON decode the body into v
+func (res *Response) JSON(v interface{}) error {
+	return json.Unmarshal(res.Body.Bytes(), v)
+}
+
+// lookupJSON walk the decoded json along path
+func lookupJSON(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// normalizeJSON round trip v through json, so 1 equals float64(1)
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return v
+	}
+	return normalized
+}
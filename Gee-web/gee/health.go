@@ -0,0 +1,215 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrShuttingDown fails the readiness probe while the Engine shuts down
+var ErrShuttingDown = errors.New("health: engine is shutting down")
+
+// HealthCheck is a named check of a component
+type HealthCheck struct {
+	// Name identifies the check in the json detail
+	Name string
+	// Check returns nil when the component is healthy, ctx is done after Timeout
+	Check func(ctx context.Context) error
+	// Timeout of the check, default HealthConfig.Timeout
+	Timeout time.Duration
+	// Critical checks fail the probe, the others are only reported
+	Critical bool
+}
+
+// HealthConfig configures NewHealth
+type HealthConfig struct {
+	// Timeout is the default timeout of a check, default 5 seconds
+	Timeout time.Duration
+	// CacheTTL reuses the result of a check for this interval, 0 runs the checks on every probe
+	CacheTTL time.Duration
+	// LivenessPath default "/healthz"
+	LivenessPath string
+	// ReadinessPath default "/readyz"
+	ReadinessPath string
+}
+
+// Health runs the liveness and readiness checks of the components:
+//
+//	health := gee.NewHealth(gee.HealthConfig{CacheTTL: time.Second})
+//	health.AddReadinessCheck(gee.HealthCheck{Name: "db", Check: db.PingContext, Critical: true})
+//	health.Register(r.RouterGroup)
+type Health struct {
+	config HealthConfig
+	mu     sync.RWMutex
+	live   []*healthCheck
+	ready  []*healthCheck
+}
+
+// healthCheck caches the last result of a HealthCheck
+type healthCheck struct {
+	HealthCheck
+	mu      sync.Mutex
+	checked time.Time
+	result  CheckResult
+}
+
+// CheckResult is the json detail of a check
+type CheckResult struct {
+	Status   string `json:"status"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// HealthReport is the json body of the probes
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// NewHealth create a Health with config
+func NewHealth(config HealthConfig) *Health {
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Second
+	}
+	if config.LivenessPath == "" {
+		config.LivenessPath = "/healthz"
+	}
+	if config.ReadinessPath == "" {
+		config.ReadinessPath = "/readyz"
+	}
+	return &Health{config: config}
+}
+
+// AddLivenessCheck add a check to /healthz, a failing liveness probe restarts the
+// process, so only checks the process can't recover from belong here
+func (h *Health) AddLivenessCheck(check HealthCheck) {
+	h.mu.Lock()
+	h.live = append(h.live, h.newCheck(check))
+	h.mu.Unlock()
+}
+
+// AddReadinessCheck add a check to /readyz, a failing readiness probe stops the traffic
+func (h *Health) AddReadinessCheck(check HealthCheck) {
+	h.mu.Lock()
+	h.ready = append(h.ready, h.newCheck(check))
+	h.mu.Unlock()
+}
+
+func (h *Health) newCheck(check HealthCheck) *healthCheck {
+	if check.Name == "" || check.Check == nil {
+		panic("gee: health check needs a name and a func")
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = h.config.Timeout
+	}
+	return &healthCheck{HealthCheck: check}
+}
+
+// Register serve the probes on g, the readiness probe fails while the
+// Engine of g shuts down
+func (h *Health) Register(g *RouterGroup) {
+	engine := g.engine
+	g.GET(h.config.LivenessPath, func(c *Context) {
+		h.respond(c, h.Liveness(c.Req.Context()), nil)
+	})
+	g.GET(h.config.ReadinessPath, func(c *Context) {
+		var shutdown error
+		if engine.IsShuttingDown() {
+			shutdown = ErrShuttingDown
+		}
+		h.respond(c, h.Readiness(c.Req.Context()), shutdown)
+	})
+}
+
+// Liveness runs the liveness checks
+func (h *Health) Liveness(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	checks := h.live
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+// Readiness runs the readiness checks
+func (h *Health) Readiness(ctx context.Context) HealthReport {
+	h.mu.RLock()
+	checks := h.ready
+	h.mu.RUnlock()
+	return h.run(ctx, checks)
+}
+
+func (h *Health) respond(c *Context, report HealthReport, shutdown error) {
+	if shutdown != nil {
+		report.Status = "fail"
+		report.Checks["shutdown"] = CheckResult{Status: "fail", Critical: true, Error: shutdown.Error(), Duration: "0s"}
+	}
+	code := http.StatusOK
+	if report.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	c.SetHeader("Cache-Control", "no-store")
+	c.JSON(code, report)
+}
+
+// run the checks concurrently, the report fails when a critical check fails
+func (h *Health) run(ctx context.Context, checks []*healthCheck) HealthReport {
+	results := make([]CheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check *healthCheck) {
+			defer wg.Done()
+			results[i] = check.run(ctx, h.config.CacheTTL)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := HealthReport{Status: "ok", Checks: make(map[string]CheckResult, len(checks))}
+	for i, check := range checks {
+		report.Checks[check.Name] = results[i]
+		if results[i].Status != "ok" && check.Critical {
+			report.Status = "fail"
+		}
+	}
+	return report
+}
+
+// run the check or return the cached result, a check ignoring ctx
+// is given up after its timeout
+func (check *healthCheck) run(ctx context.Context, ttl time.Duration) CheckResult {
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	if ttl > 0 && !check.checked.IsZero() && time.Since(check.checked) < ttl {
+		return check.result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("panic: %v", p)
+			}
+		}()
+		done <- check.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{Status: "ok", Critical: check.Critical, Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	check.checked, check.result = time.Now(), result
+	return result
+}
@@ -0,0 +1,111 @@
+package gee_test
+
+import (
+	"context"
+	"errors"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHealth(t *testing.T) {
+	var dbCalls int32
+	var dbErr atomic.Value
+	dbErr.Store("")
+	health := gee.NewHealth(gee.HealthConfig{CacheTTL: time.Hour})
+	health.AddLivenessCheck(gee.HealthCheck{
+		Name:  "goroutines",
+		Check: func(ctx context.Context) error { return nil },
+	})
+	health.AddReadinessCheck(gee.HealthCheck{
+		Name:     "db",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			atomic.AddInt32(&dbCalls, 1)
+			if msg := dbErr.Load().(string); msg != "" {
+				return errors.New(msg)
+			}
+			return nil
+		},
+	})
+	health.AddReadinessCheck(gee.HealthCheck{
+		Name:    "search",
+		Timeout: 10 * time.Millisecond,
+		Check: func(ctx context.Context) error {
+			time.Sleep(time.Second)
+			return nil
+		},
+	})
+	r := gee.New()
+	health.Register(r.RouterGroup)
+	client := geetest.New(t, r)
+
+	client.GET("/healthz").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectJSON("status", "ok").
+		ExpectJSON("checks.goroutines.status", "ok")
+	// search 超时但不是关键检查
+	client.GET("/readyz").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Cache-Control", "no-store").
+		ExpectJSON("status", "ok").
+		ExpectJSON("checks.db.critical", true).
+		ExpectJSON("checks.search.status", "fail").
+		ExpectJSON("checks.search.error", context.DeadlineExceeded.Error())
+
+	// 缓存期内不会重新检查
+	dbErr.Store("connection refused")
+	client.GET("/readyz").Do().ExpectStatus(http.StatusOK)
+	if n := atomic.LoadInt32(&dbCalls); n != 1 {
+		t.Errorf("db checked %d times, want 1", n)
+	}
+}
+
+func TestHealthFailureAndShutdown(t *testing.T) {
+	health := gee.NewHealth(gee.HealthConfig{})
+	failing := true
+	health.AddReadinessCheck(gee.HealthCheck{
+		Name:     "db",
+		Critical: true,
+		Check: func(ctx context.Context) error {
+			if failing {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	})
+	r := gee.New()
+	health.Register(r.RouterGroup)
+	client := geetest.New(t, r)
+
+	client.GET("/readyz").Do().
+		ExpectStatus(http.StatusServiceUnavailable).
+		ExpectJSON("status", "fail").
+		ExpectJSON("checks.db.error", "connection refused")
+	failing = false
+	client.GET("/readyz").Do().ExpectStatus(http.StatusOK)
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	client.GET("/readyz").Do().
+		ExpectStatus(http.StatusServiceUnavailable).
+		ExpectJSON("checks.shutdown.error", gee.ErrShuttingDown.Error())
+	client.GET("/healthz").Do().ExpectStatus(http.StatusOK)
+}
+
+func TestEngineShutdownDelay(t *testing.T) {
+	r := gee.New()
+	r.ShutdownDelay = 50 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := r.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want deadline exceeded", err)
+	}
+	if !r.IsShuttingDown() {
+		t.Error("engine is not shutting down")
+	}
+}
@@ -0,0 +1,126 @@
+package gee
+
+import (
+	"net"
+	"strings"
+)
+
+// hostRoute holds the routes registered under Engine.Host
+type hostRoute struct {
+	pattern     string
+	labels      []string
+	constraints []func(string) bool // constraints of the param labels
+	router      *router
+	noRoute     []HandlerFunc
+}
+
+func newHostRoute(pattern string) *hostRoute {
+	h := &hostRoute{pattern: pattern, router: newRouter()}
+	for _, label := range strings.Split(pattern, ".") {
+		var constraint func(string) bool
+		if !strings.Contains(label, ":") {
+			label = strings.ToLower(label)
+		} else if parseSegment(label) == nil {
+			_, c := splitParam(label)
+			constraint = compileConstraint(c)
+		}
+		h.labels = append(h.labels, label)
+		h.constraints = append(h.constraints, constraint)
+	}
+	return h
+}
+
+// Host returns a RouterGroup whose routes only match when the Host header fits
+// pattern. A label of the pattern may be a param, eg. :tenant.example.com,
+// which is read by c.Param("tenant"). Routes registered on the engine still
+// serve every host, but the host routes win, except the catch-all ones like
+// an SPA: they only serve the paths no route of the engine matches either
+func (engine *Engine) Host(pattern string) *RouterGroup {
+	pattern = strings.TrimSuffix(pattern, ".")
+	for _, group := range engine.groups {
+		if group.host != nil && group.host.pattern == pattern && group.prefix == "" {
+			return group
+		}
+	}
+	host := newHostRoute(pattern)
+	// 静态 host 优先于带参数的 host
+	i := len(engine.hosts)
+	if !strings.Contains(pattern, ":") {
+		for i > 0 && strings.Contains(engine.hosts[i-1].pattern, ":") {
+			i--
+		}
+	}
+	engine.hosts = append(engine.hosts, nil)
+	copy(engine.hosts[i+1:], engine.hosts[i:])
+	engine.hosts[i] = host
+
+	group := &RouterGroup{parent: engine.RouterGroup, engine: engine, host: host}
+	engine.groups = append(engine.groups, group)
+	return group
+}
+
+// NoRoute set the 404 handlers of the host of the group,
+// for a group serving every host it's the same as Engine.NoRoute
+func (g *RouterGroup) NoRoute(handlers ...HandlerFunc) {
+	if g.host == nil {
+		g.engine.NoRoute(handlers...)
+		return
+	}
+	g.host.noRoute = handlers
+}
+
+// matchHost returns the first host route matching host and its params
+func (engine *Engine) matchHost(host string) (*hostRoute, map[string]string) {
+	if len(engine.hosts) == 0 {
+		return nil, nil
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	labels := strings.Split(strings.ToLower(strings.TrimSuffix(host, ".")), ".")
+	for _, route := range engine.hosts {
+		if params, ok := route.match(labels); ok {
+			return route, params
+		}
+	}
+	return nil, nil
+}
+
+// match compare the labels of a host with the pattern
+func (h *hostRoute) match(labels []string) (map[string]string, bool) {
+	if len(labels) != len(h.labels) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, label := range h.labels {
+		value := labels[i]
+		if seg := parseSegment(label); seg != nil {
+			matched := seg.match(value)
+			if matched == nil {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			for k, v := range matched {
+				params[k] = v
+			}
+			continue
+		}
+		if !strings.HasPrefix(label, ":") {
+			if label != value {
+				return nil, false
+			}
+			continue
+		}
+		if value == "" || (h.constraints[i] != nil && !h.constraints[i](value)) {
+			return nil, false
+		}
+		name, _ := splitParam(label)
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = value
+	}
+	return params, true
+}
@@ -0,0 +1,143 @@
+package gee
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// HTMLRender looks up the template used by c.HTML
+type HTMLRender interface {
+	// Lookup returns the template to execute for name,
+	// reload asks the render to reparse its files first
+	Lookup(name string, reload bool) (*template.Template, error)
+}
+
+// builtinFuncs are added to every template before the user funcMap,
+// c.HTML binds them to the Context of the request with requestFuncs
+var builtinFuncs = requestFuncs(nil)
+
+// requestFuncs returns the builtin funcs bound to c
+func requestFuncs(c *Context) template.FuncMap {
+	return template.FuncMap{
+		"csrfField": func(args ...interface{}) template.HTML {
+			return csrfField(c, args...)
+		},
+		"cspNonce": func(args ...interface{}) string {
+			return cspNonce(c, args...)
+		},
+	}
+}
+
+// templateSet is a HTMLRender of a single flat template set,
+// eg. the one loaded by LoadHTMLGlob
+type templateSet struct {
+	load func() (*template.Template, error)
+	tmpl *template.Template
+}
+
+// newTemplateSet parse the set once, the loader is kept for reloading
+func newTemplateSet(load func() (*template.Template, error)) (*templateSet, error) {
+	tmpl, err := load()
+	if err != nil {
+		return nil, err
+	}
+	return &templateSet{load: load, tmpl: tmpl}, nil
+}
+
+// Lookup implements HTMLRender
+func (s *templateSet) Lookup(name string, reload bool) (*template.Template, error) {
+	tmpl := s.tmpl
+	if reload && s.load != nil {
+		var err error
+		if tmpl, err = s.load(); err != nil {
+			return nil, err
+		}
+	}
+	if t := tmpl.Lookup(name); t != nil {
+		return t, nil
+	}
+	return nil, fmt.Errorf("html/template: %q is undefined", name)
+}
+
+// MultiTemplate is a HTMLRender holding one template set per page,
+// so every page can fill the blocks of a shared base layout:
+//
+//	r := gee.NewMultiTemplate(nil)
+//	r.AddFromFiles("index", "templates/base.html", "templates/index.html")
+//	engine.SetHTMLRender(r)
+//
+// c.HTML(200, "index", data) executes base.html with the blocks of index.html
+type MultiTemplate struct {
+	mu      sync.RWMutex
+	funcMap template.FuncMap
+	pages   map[string]*templateSet
+}
+
+// NewMultiTemplate create a MultiTemplate, funcMap is added to every page
+func NewMultiTemplate(funcMap template.FuncMap) *MultiTemplate {
+	return &MultiTemplate{funcMap: funcMap, pages: make(map[string]*templateSet)}
+}
+
+// Add register a parsed page, it can't be reloaded
+func (m *MultiTemplate) Add(name string, tmpl *template.Template) {
+	m.add(name, &templateSet{tmpl: tmpl})
+}
+
+// AddFromFiles register a page parsed from files, the first file is the layout
+func (m *MultiTemplate) AddFromFiles(name string, files ...string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("gee: no files for template %q", name)
+	}
+	set, err := newTemplateSet(func() (*template.Template, error) {
+		return template.New(filepath.Base(files[0])).Funcs(builtinFuncs).Funcs(m.funcMap).ParseFiles(files...)
+	})
+	if err != nil {
+		return err
+	}
+	m.add(name, set)
+	return nil
+}
+
+// AddFromFS register a page parsed from the files of fsys matching patterns,
+// the first matched file is the layout
+func (m *MultiTemplate) AddFromFS(name string, fsys fs.FS, patterns ...string) error {
+	if len(patterns) == 0 {
+		return fmt.Errorf("gee: no patterns for template %q", name)
+	}
+	first, err := fs.Glob(fsys, patterns[0])
+	if err != nil {
+		return err
+	}
+	if len(first) == 0 {
+		return fmt.Errorf("gee: pattern %q matches no files", patterns[0])
+	}
+	set, err := newTemplateSet(func() (*template.Template, error) {
+		return template.New(path.Base(first[0])).Funcs(builtinFuncs).Funcs(m.funcMap).ParseFS(fsys, patterns...)
+	})
+	if err != nil {
+		return err
+	}
+	m.add(name, set)
+	return nil
+}
+
+func (m *MultiTemplate) add(name string, set *templateSet) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pages[name] = set
+}
+
+// Lookup implements HTMLRender, the page is executed from its layout
+func (m *MultiTemplate) Lookup(name string, reload bool) (*template.Template, error) {
+	m.mu.RLock()
+	set, ok := m.pages[name]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("gee: template %q is not registered", name)
+	}
+	return set.Lookup(set.tmpl.Name(), reload)
+}
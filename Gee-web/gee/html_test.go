@@ -0,0 +1,103 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMultiTemplate(t *testing.T) {
+	fsys := fstest.MapFS{
+		"site.html":  {Data: []byte(`<site>{{block "title" .}}site{{end}}|{{block "content" .}}{{end}}</site>`)},
+		"admin.html": {Data: []byte(`<admin>{{block "title" .}}admin{{end}}|{{block "content" .}}{{end}}</admin>`)},
+		"home.html":  {Data: []byte(`{{define "title"}}Home{{end}}{{define "content"}}home {{.}}{{end}}`)},
+		"users.html": {Data: []byte(`{{define "title"}}Users{{end}}{{define "content"}}users {{.}}{{end}}`)},
+		"about.html": {Data: []byte(`{{define "content"}}about{{end}}`)},
+	}
+	m := gee.NewMultiTemplate(nil)
+	for name, files := range map[string][]string{
+		"home":  {"site.html", "home.html"},
+		"users": {"admin.html", "users.html"},
+		"about": {"site.html", "about.html"},
+	} {
+		if err := m.AddFromFS(name, fsys, files...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.AddFromFS("missing", fsys, "nothing-*.html"); err == nil {
+		t.Error("AddFromFS should fail when the layout pattern matches no files")
+	}
+	r := gee.New()
+	r.SetHTMLRender(m)
+	r.GET("/:page", func(c *gee.Context) {
+		c.HTML(http.StatusOK, c.Param("page"), "tom")
+	})
+	client := geetest.New(t, r)
+
+	// 两个页面定义了同名的 block，互不覆盖
+	client.GET("/home").Do().ExpectStatus(http.StatusOK).ExpectBody("<site>Home|home tom</site>")
+	client.GET("/users").Do().ExpectStatus(http.StatusOK).ExpectBody("<admin>Users|users tom</admin>")
+	client.GET("/about").Do().ExpectStatus(http.StatusOK).ExpectBody("<site>site|about</site>")
+	client.GET("/missing").Do().ExpectStatus(http.StatusInternalServerError)
+}
+
+func TestLoadHTMLFilesError(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.html")
+	bad := filepath.Join(dir, "bad.html")
+	writeFile(t, good, `good {{.}}`)
+	writeFile(t, bad, `bad {{.`)
+
+	r := gee.New()
+	r.HTMLAutoReload = false
+	if err := r.LoadHTMLFiles(good); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.LoadHTMLFiles(filepath.Join(dir, "missing.html")); err == nil {
+		t.Error("LoadHTMLFiles should fail for a missing file")
+	}
+	if err := r.LoadHTMLFiles(good, bad); err == nil {
+		t.Error("LoadHTMLFiles should fail for a template with a syntax error")
+	}
+	if err := gee.NewMultiTemplate(nil).AddFromFiles("page", good, bad); err == nil {
+		t.Error("AddFromFiles should fail for a template with a syntax error")
+	}
+	r.GET("/", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "good.html", "tom")
+	})
+	// 加载失败时保留之前的模板
+	geetest.New(t, r).GET("/").Do().ExpectStatus(http.StatusOK).ExpectBody("good tom")
+}
+
+func TestHTMLAutoReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "page.html")
+	writeFile(t, file, `v1 {{.}}`)
+
+	r := gee.New()
+	r.HTMLAutoReload = true
+	if err := r.LoadHTMLFiles(file); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "page.html", "tom")
+	})
+	client := geetest.New(t, r)
+	client.GET("/").Do().ExpectBody("v1 tom")
+
+	writeFile(t, file, `v2 {{.}}`)
+	client.GET("/").Do().ExpectStatus(http.StatusOK).ExpectBody("v2 tom")
+
+	writeFile(t, file, `v3 {{.`)
+	client.GET("/").Do().ExpectStatus(http.StatusInternalServerError)
+}
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
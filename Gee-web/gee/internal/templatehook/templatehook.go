@@ -0,0 +1,19 @@
+// Package templatehook carries a per-request hook called with the name of
+// every template rendered by c.HTML, geetest records the templates with it
+package templatehook
+
+import "context"
+
+type hookKey struct{}
+
+// With returns a copy of ctx carrying hook
+func With(ctx context.Context, hook func(name string)) context.Context {
+	return context.WithValue(ctx, hookKey{}, hook)
+}
+
+// Call calls the hook carried by ctx, if any
+func Call(ctx context.Context, name string) {
+	if hook, ok := ctx.Value(hookKey{}).(func(string)); ok {
+		hook(name)
+	}
+}
@@ -0,0 +1,378 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWT errors
+var (
+	ErrTokenMalformed   = errors.New("jwt: malformed token")
+	ErrTokenAlgorithm   = errors.New("jwt: unsupported or unexpected algorithm")
+	ErrTokenUnknownKey  = errors.New("jwt: unknown signing key")
+	ErrTokenSignature   = errors.New("jwt: invalid signature")
+	ErrTokenExpired     = errors.New("jwt: token is expired")
+	ErrTokenNotValidYet = errors.New("jwt: token is not valid yet")
+	ErrTokenIssuer      = errors.New("jwt: invalid issuer")
+	ErrTokenAudience    = errors.New("jwt: invalid audience")
+)
+
+// JWTKey is a key of a KeySet. Key is a []byte secret for HS256, HS384 and HS512,
+// a *rsa.PublicKey for RS256 and a *ecdsa.PublicKey on P-256 for ES256.
+// The matching private keys are accepted too, they can sign with SignJWT
+type JWTKey struct {
+	ID        string
+	Algorithm string
+	Key       interface{}
+}
+
+// KeySet holds the keys used to verify tokens, keys are chosen by the kid
+// header so they can be rotated by adding the new key before removing the old one
+type KeySet struct {
+	mu   sync.RWMutex
+	keys []JWTKey
+}
+
+// NewKeySet create a KeySet holding keys
+func NewKeySet(keys ...JWTKey) *KeySet {
+	s := &KeySet{}
+	for _, key := range keys {
+		s.Add(key)
+	}
+	return s
+}
+
+// Add add or replace the key with the same ID
+func (s *KeySet) Add(key JWTKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.keys {
+		if k.ID == key.ID {
+			s.keys[i] = key
+			return
+		}
+	}
+	s.keys = append(s.keys, key)
+}
+
+// Remove remove the key with the given ID
+func (s *KeySet) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, k := range s.keys {
+		if k.ID == id {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// candidates returns the keys which may have signed a token,
+// all the keys of the algorithm when the token has no kid
+func (s *KeySet) candidates(kid, alg string) []JWTKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []JWTKey
+	for _, k := range s.keys {
+		if k.Algorithm != alg {
+			continue
+		}
+		if kid == "" || k.ID == kid {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// JWTConfig configures JWTAuth and ParseJWT
+type JWTConfig struct {
+	// Keys verifying the signature
+	Keys *KeySet
+	// Issuer is compared to the iss claim when not empty
+	Issuer string
+	// Audience must be one of the aud claim when not empty
+	Audience string
+	// Leeway tolerates clock skew for exp and nbf
+	Leeway time.Duration
+	// Now returns the current time, default time.Now
+	Now func() time.Time
+}
+
+// JWTAuth middleware verifies the Authorization: Bearer token,
+// the claims are available through c.Principal()
+func JWTAuth(config JWTConfig) HandlerFunc {
+	return func(c *Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.SetHeader("WWW-Authenticate", `Bearer`)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		claims, err := ParseJWT(token, config)
+		if err != nil {
+			c.SetHeader("WWW-Authenticate", `Bearer error="invalid_token"`)
+			c.Error(err).SetStatus(http.StatusUnauthorized)
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		subject, _ := claims["sub"].(string)
+		c.SetPrincipal(&Principal{Subject: subject, Scheme: "bearer", Claims: claims})
+		c.Next()
+	}
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid,omitempty"`
+	Type      string `json:"typ,omitempty"`
+}
+
+// ParseJWT verify the signature and the registered claims of token
+func ParseJWT(token string, config JWTConfig) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if _, ok := jwtHashes[header.Algorithm]; !ok {
+		return nil, ErrTokenAlgorithm
+	}
+	if config.Keys == nil {
+		return nil, ErrTokenUnknownKey
+	}
+	keys := config.Keys.candidates(header.KeyID, header.Algorithm)
+	if len(keys) == 0 {
+		return nil, ErrTokenUnknownKey
+	}
+	signed := parts[0] + "." + parts[1]
+	err = ErrTokenSignature
+	for _, key := range keys {
+		if err = verifyJWT(header.Algorithm, key.Key, signed, signature); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if err := validateClaims(claims, config); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// SignJWT sign claims with key, it's mostly used by tests and internal services
+func SignJWT(claims map[string]interface{}, key JWTKey) (string, error) {
+	h, ok := jwtHashes[key.Algorithm]
+	if !ok {
+		return "", ErrTokenAlgorithm
+	}
+	header, err := json.Marshal(jwtHeader{Algorithm: key.Algorithm, KeyID: key.ID, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := hashOf(h, signed)
+	var signature []byte
+	switch k := key.Key.(type) {
+	case []byte:
+		if !strings.HasPrefix(key.Algorithm, "HS") {
+			return "", ErrTokenAlgorithm
+		}
+		mac := hmac.New(hashFunc(h), k)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if key.Algorithm != "RS256" {
+			return "", ErrTokenAlgorithm
+		}
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, h, digest); err != nil {
+			return "", err
+		}
+	case *ecdsa.PrivateKey:
+		if key.Algorithm != "ES256" || k.Curve != elliptic.P256() {
+			return "", ErrTokenAlgorithm
+		}
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return "", err
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	default:
+		return "", ErrTokenUnknownKey
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// jwtHashes are the supported algorithms
+var jwtHashes = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"ES256": crypto.SHA256,
+}
+
+// verifyJWT check the signature, the type of key must fit the algorithm,
+// so a RSA public key can never be used as a HMAC secret
+func verifyJWT(alg string, key interface{}, signed string, signature []byte) error {
+	h := jwtHashes[alg]
+	switch {
+	case strings.HasPrefix(alg, "HS"):
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		mac := hmac.New(hashFunc(h), secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrTokenSignature
+		}
+		return nil
+	case alg == "RS256":
+		var pub *rsa.PublicKey
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			pub = k
+		case *rsa.PrivateKey:
+			pub = &k.PublicKey
+		default:
+			return ErrTokenAlgorithm
+		}
+		if rsa.VerifyPKCS1v15(pub, h, hashOf(h, signed), signature) != nil {
+			return ErrTokenSignature
+		}
+		return nil
+	case alg == "ES256":
+		var pub *ecdsa.PublicKey
+		switch k := key.(type) {
+		case *ecdsa.PublicKey:
+			pub = k
+		case *ecdsa.PrivateKey:
+			pub = &k.PublicKey
+		default:
+			return ErrTokenAlgorithm
+		}
+		if pub.Curve != elliptic.P256() || len(signature) != 64 {
+			return ErrTokenSignature
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, hashOf(h, signed), r, s) {
+			return ErrTokenSignature
+		}
+		return nil
+	}
+	return ErrTokenAlgorithm
+}
+
+// validateClaims check exp, nbf, iss and aud
+func validateClaims(claims map[string]interface{}, config JWTConfig) error {
+	now := time.Now()
+	if config.Now != nil {
+		now = config.Now()
+	}
+	if exp, ok, err := numericDate(claims, "exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(config.Leeway)) {
+		return ErrTokenExpired
+	}
+	if nbf, ok, err := numericDate(claims, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(config.Leeway).Before(nbf) {
+		return ErrTokenNotValidYet
+	}
+	if config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != config.Issuer {
+			return ErrTokenIssuer
+		}
+	}
+	if config.Audience != "" && !hasAudience(claims["aud"], config.Audience) {
+		return ErrTokenAudience
+	}
+	return nil
+}
+
+// numericDate read a NumericDate claim
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	v, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	if n, ok := v.(float64); ok {
+		sec := int64(n)
+		return time.Unix(sec, int64((n-float64(sec))*1e9)), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%w: %s is not a number", ErrTokenMalformed, name)
+}
+
+// hasAudience reports whether the aud claim, a string or an array, contains audience
+func hasAudience(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func hashFunc(h crypto.Hash) func() hash.Hash {
+	switch h {
+	case crypto.SHA384:
+		return sha512.New384
+	case crypto.SHA512:
+		return sha512.New
+	}
+	return sha256.New
+}
+
+func hashOf(h crypto.Hash, signed string) []byte {
+	hasher := hashFunc(h)()
+	hasher.Write([]byte(signed))
+	return hasher.Sum(nil)
+}
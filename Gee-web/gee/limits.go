@@ -0,0 +1,217 @@
+package gee
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of the Engine limits
+const (
+	DefaultMaxHeaderBytes   = 1 << 20
+	DefaultMaxHeaderCount   = 100
+	DefaultMinReadRateGrace = 5 * time.Second
+)
+
+// defaultMultipartMemory is the memory of PostForm for multipart forms, like Request.FormValue
+const defaultMultipartMemory = 32 << 20
+
+// ErrBodyReadRate is returned by the body reads of a client uploading slower than Engine.MinReadRate
+var ErrBodyReadRate = errors.New("gee: request body read rate too low")
+
+// body states of limitedBody
+const (
+	bodyOK int32 = iota
+	bodyTooLarge
+	bodyTooSlow
+)
+
+// limitedBody bounds the request body with http.MaxBytesReader and a minimum read rate
+type limitedBody struct {
+	orig  io.ReadCloser
+	r     io.ReadCloser
+	w     http.ResponseWriter
+	limit int64
+
+	// minRate bytes per second are required once grace has passed
+	minRate  int64
+	grace    time.Duration
+	start    time.Time
+	read     int64
+	deadline bool // the connection supports read deadlines
+
+	state    atomic.Int32
+	reported atomic.Bool
+}
+
+// limitBody wrap the body of c with the limits of the engine
+func (engine *Engine) limitBody(c *Context) {
+	if c.Req.Body == nil || c.Req.Body == http.NoBody {
+		return
+	}
+	body := &limitedBody{
+		orig:    c.Req.Body,
+		w:       serverWriter(c.Writer),
+		minRate: engine.MinReadRate,
+		grace:   engine.MinReadRateGrace,
+		start:   time.Now(),
+	}
+	if body.grace <= 0 {
+		body.grace = DefaultMinReadRateGrace
+	}
+	body.setLimit(engine.MaxBodyBytes)
+	if body.minRate > 0 {
+		body.deadline = http.NewResponseController(c.Writer).SetReadDeadline(body.nextDeadline()) == nil
+	}
+	c.body = body
+	c.Req.Body = body
+}
+
+// serverWriter returns the http.ResponseWriter of the server under the wrappers,
+// http.MaxBytesReader closes the connection after the reply only through it
+func serverWriter(w http.ResponseWriter) http.ResponseWriter {
+	for {
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return w
+		}
+		w = u.Unwrap()
+	}
+}
+
+// setLimit replace the limit, n <= 0 removes it
+func (b *limitedBody) setLimit(n int64) {
+	b.limit = n
+	if n > 0 {
+		b.r = http.MaxBytesReader(b.w, b.orig, n)
+	} else {
+		b.r = b.orig
+	}
+}
+
+// nextDeadline is the time the next byte must arrive to keep the average rate
+func (b *limitedBody) nextDeadline() time.Time {
+	return b.start.Add(b.grace + time.Duration(float64(b.read+1)/float64(b.minRate)*float64(time.Second)))
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.read += int64(n)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		b.state.CompareAndSwap(bodyOK, bodyTooLarge)
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		b.state.CompareAndSwap(bodyOK, bodyTooSlow)
+		err = ErrBodyReadRate
+	case err == nil && b.minRate > 0:
+		// 不支持读超时的连接只能在读之后检查
+		if !b.deadline && time.Now().After(b.nextDeadline()) {
+			b.state.CompareAndSwap(bodyOK, bodyTooSlow)
+			return n, ErrBodyReadRate
+		}
+		if b.deadline {
+			http.NewResponseController(b.w).SetReadDeadline(b.nextDeadline())
+		}
+	}
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.orig.Close()
+}
+
+// bodyError collect the error of a body which broke a limit, once per request,
+// so ErrorHandler can answer it as a problem
+func (c *Context) bodyError() *Error {
+	if c.body == nil {
+		return nil
+	}
+	var err error
+	var code int
+	switch c.body.state.Load() {
+	case bodyTooLarge:
+		err, code = &http.MaxBytesError{Limit: c.body.limit}, http.StatusRequestEntityTooLarge
+	case bodyTooSlow:
+		err, code = ErrBodyReadRate, http.StatusRequestTimeout
+	default:
+		return nil
+	}
+	e := &Error{Err: err, Type: ErrorTypePublic, Status: code}
+	if c.body.reported.CompareAndSwap(false, true) {
+		c.Error(e)
+	}
+	return e
+}
+
+// finish answer 413 or 408 when the body broke a limit, a handler which read
+// c.Req.Body itself and wrote a response anyway keeps its response
+func (b *limitedBody) finish(c *Context) {
+	e := c.bodyError()
+	if e == nil {
+		if b.deadline {
+			http.NewResponseController(b.w).SetReadDeadline(time.Time{})
+		}
+		return
+	}
+	if !c.Written() {
+		c.writeBodyError(e)
+	}
+}
+
+// abortBody abort the request with 413 or 408 when err comes from a body over the
+// engine limits, the response is written at once so the handler can't answer 200
+func (c *Context) abortBody(err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) && !errors.Is(err, ErrBodyReadRate) {
+		return false
+	}
+	c.Abort()
+	if e := c.bodyError(); e != nil && !c.Written() {
+		c.writeBodyError(e)
+		// 后面 handler 写的响应被丢弃
+		c.Writer = &discardWriter{header: http.Header{}}
+	}
+	return true
+}
+
+// writeBodyError write the response of a body which broke a limit
+func (c *Context) writeBodyError(e *Error) {
+	if e.Status == http.StatusRequestEntityTooLarge {
+		c.String(e.Status, "413 REQUEST ENTITY TOO LARGE: request body exceeds %d bytes\n", c.body.limit)
+		return
+	}
+	// 不能再读剩下的 body，关闭连接
+	c.SetHeader("Connection", "close")
+	c.String(e.Status, "408 REQUEST TIMEOUT: %s\n", e.Err)
+}
+
+// BodyLimit middleware overrides Engine.MaxBodyBytes for a route or a group,
+// n <= 0 removes the limit. It must run before the body is read:
+//
+//	r.POST("/upload", gee.BodyLimit(1<<30), upload)
+func BodyLimit(n int64) HandlerFunc {
+	return func(c *Context) {
+		if c.body != nil {
+			c.body.setLimit(n)
+		}
+		c.Next()
+	}
+}
+
+// checkHeaders reports whether the header of req is within Engine.MaxHeaderCount.
+// The size is bounded by http.Server.MaxHeaderBytes before the handler runs, Run
+// sets it to Engine.MaxHeaderBytes; the count is a policy checked on top of it
+func (engine *Engine) checkHeaders(req *http.Request) bool {
+	if engine.MaxHeaderCount <= 0 {
+		return true
+	}
+	count := 0
+	for _, values := range req.Header {
+		count += len(values)
+	}
+	return count <= engine.MaxHeaderCount
+}
@@ -0,0 +1,213 @@
+package gee_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"gee"
+	"gee/geetest"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxBodyBytes(t *testing.T) {
+	r := gee.New()
+	r.MaxBodyBytes = 16
+	r.Use(gee.ErrorHandler())
+	r.POST("/form", func(c *gee.Context) {
+		if err := c.Req.ParseForm(); err != nil {
+			c.Error(err)
+			return
+		}
+		c.String(http.StatusOK, "%s", c.PostForm("name"))
+	})
+	r.POST("/json", func(c *gee.Context) {
+		var v map[string]string
+		if err := json.NewDecoder(c.Req.Body).Decode(&v); err != nil {
+			c.Error(err)
+			return
+		}
+		c.JSON(http.StatusOK, v)
+	})
+	r.POST("/upload", gee.BodyLimit(64), func(c *gee.Context) {
+		data, err := io.ReadAll(c.Req.Body)
+		if err != nil {
+			c.Error(err)
+			return
+		}
+		c.String(http.StatusOK, "%d", len(data))
+	})
+	client := geetest.New(t, r)
+
+	client.POST("/form").Body(strings.NewReader("name=tom")).
+		Header("Content-Type", "application/x-www-form-urlencoded").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("tom")
+	// ErrorHandler 返回 413
+	client.POST("/form").Body(strings.NewReader("name="+strings.Repeat("a", 32))).
+		Header("Content-Type", "application/x-www-form-urlencoded").Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge).
+		ExpectJSON("status", http.StatusRequestEntityTooLarge).
+		ExpectJSON("detail", "http: request body too large")
+	client.POST("/json").JSON(gee.H{"name": strings.Repeat("a", 32)}).Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge)
+	client.POST("/upload").Body(strings.NewReader(strings.Repeat("a", 48))).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("48")
+	client.POST("/upload").Body(strings.NewReader(strings.Repeat("a", 65))).Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge)
+}
+
+func TestMaxBodyBytesWithoutErrorHandler(t *testing.T) {
+	r := gee.New()
+	r.MaxBodyBytes = 4
+	r.POST("/", func(c *gee.Context) {
+		io.ReadAll(c.Req.Body)
+	})
+	geetest.New(t, r).POST("/").Body(strings.NewReader("too large")).Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge).
+		ExpectBody("413 REQUEST ENTITY TOO LARGE: request body exceeds 4 bytes\n")
+}
+
+// 忽略了 PostForm 和 BindJSON 错误的 handler 不能返回 200
+func TestBodyLimitAbortsBinding(t *testing.T) {
+	r := gee.New()
+	r.MaxBodyBytes = 16
+	r.Use(gee.ErrorHandler())
+	r.POST("/form", func(c *gee.Context) {
+		c.String(http.StatusOK, "name=%s", c.PostForm("name"))
+	})
+	r.POST("/json", func(c *gee.Context) {
+		var v map[string]string
+		c.BindJSON(&v)
+		c.JSON(http.StatusOK, v)
+	})
+	client := geetest.New(t, r)
+
+	client.POST("/form").Form(url.Values{"name": {"tom"}}).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("name=tom")
+	client.POST("/form").Form(url.Values{"name": {strings.Repeat("a", 32)}}).Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge).
+		ExpectBody("413 REQUEST ENTITY TOO LARGE: request body exceeds 16 bytes\n")
+	client.POST("/json").JSON(gee.H{"name": "tom"}).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectJSON("name", "tom")
+	client.POST("/json").JSON(gee.H{"name": strings.Repeat("a", 32)}).Do().
+		ExpectStatus(http.StatusRequestEntityTooLarge).
+		ExpectBody("413 REQUEST ENTITY TOO LARGE: request body exceeds 16 bytes\n")
+}
+
+func TestHeaderLimits(t *testing.T) {
+	r := gee.New()
+	r.MaxHeaderCount = 3
+	r.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	client := geetest.New(t, r)
+
+	client.GET("/").Header("X-A", "1").Do().ExpectStatus(http.StatusOK)
+	req := client.GET("/")
+	for i := 0; i < 4; i++ {
+		req.Header("X-"+strconv.Itoa(i), "1")
+	}
+	req.Do().ExpectStatus(http.StatusRequestHeaderFieldsTooLarge)
+}
+
+// slowReader returns a byte every delay
+type slowReader struct {
+	n     int
+	delay time.Duration
+}
+
+func (r *slowReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	time.Sleep(r.delay)
+	r.n--
+	p[0] = 'a'
+	return 1, nil
+}
+
+func TestMinReadRate(t *testing.T) {
+	r := gee.New()
+	r.MinReadRate = 1000
+	r.MinReadRateGrace = 10 * time.Millisecond
+	r.POST("/", func(c *gee.Context) {
+		if _, err := io.ReadAll(c.Req.Body); err != nil {
+			return
+		}
+		c.String(http.StatusOK, "ok")
+	})
+	client := geetest.New(t, r)
+
+	client.POST("/").Body(strings.NewReader(strings.Repeat("a", 100))).Do().ExpectStatus(http.StatusOK)
+	client.POST("/").Body(&slowReader{n: 5, delay: 20 * time.Millisecond}).Do().
+		ExpectStatus(http.StatusRequestTimeout)
+}
+
+func TestMinReadRateDeadline(t *testing.T) {
+	r := gee.New()
+	r.MinReadRate = 1000
+	r.MinReadRateGrace = 50 * time.Millisecond
+	r.POST("/", func(c *gee.Context) {
+		io.ReadAll(c.Req.Body)
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	// 客户端只发送了一个字节就停下，读超时让 handler 返回
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\na")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("status = %d, want 408", res.StatusCode)
+	}
+}
+
+func TestMaxBodyBytesDefault(t *testing.T) {
+	r := gee.New()
+	if r.MaxBodyBytes != 0 {
+		t.Errorf("MaxBodyBytes = %d, want no limit", r.MaxBodyBytes)
+	}
+	r.POST("/", func(c *gee.Context) {
+		data, _ := io.ReadAll(c.Req.Body)
+		c.String(http.StatusOK, "%d", len(data))
+	})
+	geetest.New(t, r).POST("/").Body(strings.NewReader(strings.Repeat("a", 40<<20))).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody(strconv.Itoa(40 << 20))
+}
+
+func TestMaxBodyBytesClosesConnection(t *testing.T) {
+	r := gee.New()
+	r.MaxBodyBytes = 4
+	r.POST("/", func(c *gee.Context) {
+		io.ReadAll(c.Req.Body)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL, "text/plain", strings.NewReader(strings.Repeat("a", 64)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	// MaxBytesReader 拿到服务器的 ResponseWriter 才会在响应后关闭连接
+	if res.StatusCode != http.StatusRequestEntityTooLarge || !res.Close {
+		t.Errorf("status %d, close %v", res.StatusCode, res.Close)
+	}
+}
@@ -12,7 +12,7 @@ func Logger() HandlerFunc {
 		t := time.Now()
 		c.Next()
 		// Calculate resolution time
-		log.Printf("logger : [%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		log.Printf("logger : [%d] %s %s in %v", c.StatusCode, c.ClientIP(), c.Req.RequestURI, time.Since(t))
 	}
 }
 
@@ -0,0 +1,247 @@
+package gee
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the upper bounds in seconds of the latency histogram
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds in bytes of the response size histogram
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+
+// unmatchedRoute is the route label of the requests matching no route,
+// the raw path would give a series per url
+const unmatchedRoute = "unmatched"
+
+// MetricsConfig configures NewMetrics
+type MetricsConfig struct {
+	// Namespace prefixes the metric names, default "gee"
+	Namespace string
+	// LatencyBuckets default DefaultLatencyBuckets
+	LatencyBuckets []float64
+	// SizeBuckets default DefaultSizeBuckets
+	SizeBuckets []float64
+	// Skipper skips recording when it returns true, eg. for the metrics route itself
+	Skipper func(c *Context) bool
+}
+
+// Metrics records the requests in the Prometheus text exposition format:
+//
+//	m := gee.NewMetrics(gee.MetricsConfig{})
+//	r.Use(m.Middleware())
+//	r.GET("/metrics", m.Handler())
+type Metrics struct {
+	config   MetricsConfig
+	requests *metricVec
+	latency  *metricVec
+	size     *metricVec
+	inFlight *metricVec
+}
+
+// NewMetrics create a Metrics with config
+func NewMetrics(config MetricsConfig) *Metrics {
+	if config.Namespace == "" {
+		config.Namespace = "gee"
+	}
+	if config.LatencyBuckets == nil {
+		config.LatencyBuckets = DefaultLatencyBuckets
+	}
+	if config.SizeBuckets == nil {
+		config.SizeBuckets = DefaultSizeBuckets
+	}
+	ns := config.Namespace + "_http_"
+	return &Metrics{
+		config: config,
+		requests: newMetricVec(ns+"requests_total", "Total number of HTTP requests.",
+			"counter", nil, "method", "route", "status"),
+		latency: newMetricVec(ns+"request_duration_seconds", "HTTP request latency in seconds.",
+			"histogram", config.LatencyBuckets, "method", "route", "status"),
+		size: newMetricVec(ns+"response_size_bytes", "HTTP response size in bytes.",
+			"histogram", config.SizeBuckets, "method", "route", "status"),
+		inFlight: newMetricVec(ns+"requests_in_flight", "Number of HTTP requests being served.",
+			"gauge", nil, "method", "route"),
+	}
+}
+
+// Middleware records every request, the route label is the matched pattern
+func (m *Metrics) Middleware() HandlerFunc {
+	return func(c *Context) {
+		if m.config.Skipper != nil && m.config.Skipper(c) {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := metricMethod(c.Method)
+		inFlight := m.inFlight.with(method, route)
+		inFlight.add(1)
+		start := time.Now()
+		defer func() {
+			inFlight.add(-1)
+			// 发生 panic 时 Recovery 还没有写响应，按 500 记录
+			status := c.writer.status
+			if p := recover(); p != nil {
+				if !c.writer.Written() {
+					status = http.StatusInternalServerError
+				}
+				defer panic(p)
+			}
+			code := strconv.Itoa(status)
+			m.requests.with(method, route, code).add(1)
+			m.latency.with(method, route, code).observe(time.Since(start).Seconds())
+			m.size.with(method, route, code).observe(float64(c.writer.size))
+		}()
+		c.Next()
+	}
+}
+
+// metricMethod returns the method label, the methods outside of the standard
+// ones are "OTHER" so that clients can't create series at will
+func metricMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+// Handler serves the metrics in the Prometheus text exposition format
+func (m *Metrics) Handler() HandlerFunc {
+	return func(c *Context) {
+		c.SetHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		w := bufio.NewWriter(c.Writer)
+		for _, v := range []*metricVec{m.requests, m.latency, m.size, m.inFlight} {
+			v.writeTo(w)
+		}
+		w.Flush()
+	}
+}
+
+// metricVec is a metric family partitioned by labels
+type metricVec struct {
+	name    string
+	help    string
+	typ     string
+	buckets []float64
+	labels  []string
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newMetricVec(name, help, typ string, buckets []float64, labels ...string) *metricVec {
+	return &metricVec{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		buckets: buckets,
+		labels:  labels,
+		series:  make(map[string]*series),
+	}
+}
+
+// series is a counter, a gauge or a histogram with its label values
+type series struct {
+	mu      sync.Mutex
+	values  []string
+	value   float64
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// with returns the series of the label values, creating it on first use
+func (v *metricVec) with(values ...string) *series {
+	key := strings.Join(values, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{values: values, buckets: v.buckets}
+		if v.typ == "histogram" {
+			s.counts = make([]uint64, len(v.buckets))
+		}
+		v.series[key] = s
+	}
+	return s
+}
+
+func (s *series) add(delta float64) {
+	s.mu.Lock()
+	s.value += delta
+	s.mu.Unlock()
+}
+
+func (s *series) observe(value float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// counts 不是累积的，输出时再累加
+	for i, upper := range s.buckets {
+		if value <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// writeTo write the family in the text exposition format, series sorted by labels
+func (v *metricVec) writeTo(w *bufio.Writer) {
+	v.mu.Lock()
+	all := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		all = append(all, s)
+	}
+	v.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, v.help, v.name, v.typ)
+	for _, s := range all {
+		labels := v.labelPairs(s.values)
+		s.mu.Lock()
+		if v.typ != "histogram" {
+			fmt.Fprintf(w, "%s{%s} %s\n", v.name, labels, formatFloat(s.value))
+			s.mu.Unlock()
+			continue
+		}
+		var cumulative uint64
+		for i, upper := range v.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", v.name, labels, formatFloat(upper), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", v.name, labels, s.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", v.name, labels, formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count{%s} %d\n", v.name, labels, s.count)
+		s.mu.Unlock()
+	}
+}
+
+func (v *metricVec) labelPairs(values []string) string {
+	pairs := make([]string, len(values))
+	for i, value := range values {
+		pairs[i] = v.labels[i] + `="` + labelEscaper.Replace(value) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,64 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := gee.NewMetrics(gee.MetricsConfig{
+		Namespace:   "app",
+		SizeBuckets: []float64{1, 10},
+		Skipper:     func(c *gee.Context) bool { return c.FullPath() == "/metrics" },
+	})
+	r := gee.New()
+	r.Use(gee.Recovery(), m.Middleware())
+	r.GET("/user/:id", func(c *gee.Context) {
+		c.String(http.StatusOK, "user %s", c.Param("id"))
+	})
+	r.GET("/panic", func(c *gee.Context) {
+		panic("boom")
+	})
+	r.GET("/metrics", m.Handler())
+	client := geetest.New(t, r)
+
+	client.GET("/user/1").Do().ExpectStatus(http.StatusOK)
+	client.GET("/user/2").Do().ExpectStatus(http.StatusOK)
+	client.GET("/nope").Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/panic").Do().ExpectStatus(http.StatusInternalServerError)
+	// 非标准方法合并为 OTHER，不能随意制造新的时间序列
+	client.Request("PROPFIND", "/user/1").Do()
+	client.Request("X-RANDOM-1", "/user/1").Do()
+
+	res := client.GET("/metrics").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	body := res.Body.String()
+	for _, want := range []string{
+		"# TYPE app_http_requests_total counter\n",
+		`app_http_requests_total{method="GET",route="/user/:id",status="200"} 2` + "\n",
+		`app_http_requests_total{method="GET",route="unmatched",status="404"} 1` + "\n",
+		`app_http_requests_total{method="GET",route="/panic",status="500"} 1` + "\n",
+		"# TYPE app_http_request_duration_seconds histogram\n",
+		`app_http_request_duration_seconds_count{method="GET",route="/user/:id",status="200"} 2` + "\n",
+		`app_http_response_size_bytes_bucket{method="GET",route="/user/:id",status="200",le="1"} 0` + "\n",
+		`app_http_response_size_bytes_bucket{method="GET",route="/user/:id",status="200",le="10"} 2` + "\n",
+		`app_http_response_size_bytes_bucket{method="GET",route="/user/:id",status="200",le="+Inf"} 2` + "\n",
+		`app_http_response_size_bytes_sum{method="GET",route="/user/:id",status="200"} 12` + "\n",
+		`app_http_requests_in_flight{method="GET",route="/user/:id"} 0` + "\n",
+		`app_http_requests_total{method="OTHER",route="unmatched",status="405"} 2` + "\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics miss %q\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "PROPFIND") || strings.Contains(body, "X-RANDOM") {
+		t.Error("custom methods are labels")
+	}
+	if strings.Contains(body, `route="/metrics"`) {
+		t.Error("skipped route is recorded")
+	}
+}
@@ -0,0 +1,77 @@
+package gee
+
+import (
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+// EnvGeeMode is the environment variable used to choose the run mode
+const EnvGeeMode = "GEE_MODE"
+
+const (
+	// DebugMode logs routes, reloads templates and shows panic details
+	DebugMode = "debug"
+	// ReleaseMode is quiet and safe for production
+	ReleaseMode = "release"
+	// TestMode is quiet like release and skips the Logger in Default
+	TestMode = "test"
+)
+
+const (
+	debugCode = iota
+	releaseCode
+	testCode
+)
+
+var (
+	geeMode  int32 = debugCode
+	modeName atomic.Value
+)
+
+func init() {
+	SetMode(os.Getenv(EnvGeeMode))
+}
+
+// SetMode set the run mode, an empty value means debug
+func SetMode(value string) {
+	switch value {
+	case DebugMode, "":
+		value = DebugMode
+		atomic.StoreInt32(&geeMode, debugCode)
+	case ReleaseMode:
+		atomic.StoreInt32(&geeMode, releaseCode)
+	case TestMode:
+		atomic.StoreInt32(&geeMode, testCode)
+	default:
+		panic("gee mode unknown: " + value + " (available mode: debug release test)")
+	}
+	modeName.Store(value)
+}
+
+// Mode returns the current run mode
+func Mode() string {
+	return modeName.Load().(string)
+}
+
+// IsDebugging returns true when running in debug mode
+func IsDebugging() bool {
+	return atomic.LoadInt32(&geeMode) == debugCode
+}
+
+// debugPrint only logs in debug mode
+func debugPrint(format string, values ...interface{}) {
+	if IsDebugging() {
+		log.Printf("[GEE-debug] "+format, values...)
+	}
+}
+
+// debugPrintWARNING warns about the debug settings, only in debug mode
+func debugPrintWARNING(format string, values ...interface{}) {
+	debugPrint("[WARNING] "+format, values...)
+}
+
+// warnPrint logs failures and unsafe settings in every mode, release included
+func warnPrint(format string, values ...interface{}) {
+	log.Printf("[GEE-warning] "+format, values...)
+}
@@ -0,0 +1,451 @@
+package gee
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Route is a registered route, its Meta documents it in the OpenAPI document
+type Route struct {
+	Method string
+	// Path is the full pattern, group prefix included
+	Path string
+	// Host is the pattern of Engine.Host, empty for every host
+	Host string
+	Meta RouteMeta
+}
+
+// RouteMeta is the optional documentation of a route
+type RouteMeta struct {
+	Summary     string
+	Description string
+	Tags        []string
+	OperationID string
+	Deprecated  bool
+	// Hidden leaves the route out of the OpenAPI document
+	Hidden bool
+	// Query is a struct whose form tagged fields are the query parameters
+	Query interface{}
+	// Request is the json body, fields tagged binding:"required" are required
+	Request interface{}
+	// Responses maps status codes to json bodies, nil for a response without body
+	Responses map[int]interface{}
+}
+
+// Doc attach meta to the route:
+//
+//	r.POST("/users", createUser).Doc(gee.RouteMeta{
+//		Summary:   "Create a user",
+//		Request:   CreateUser{},
+//		Responses: map[int]interface{}{201: User{}, 400: gee.Problem{}},
+//	})
+func (r *Route) Doc(meta RouteMeta) *Route {
+	r.Meta = meta
+	return r
+}
+
+// Routes returns the registered routes in order
+func (engine *Engine) Routes() []*Route {
+	return append([]*Route(nil), engine.routes...)
+}
+
+// OpenAPIConfig configures the OpenAPI document
+type OpenAPIConfig struct {
+	Title       string
+	Version     string
+	Description string
+	// Servers are the base urls of the api
+	Servers []string
+	// Host documents the routes of Engine.Host(Host) along with the global ones,
+	// which they override like they do when routing; the routes of the other
+	// hosts are left out. Empty documents only the global routes
+	Host string
+}
+
+// OpenAPIDocument is an OpenAPI 3.0 document
+type OpenAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Servers    []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components,omitempty"`
+}
+
+// OpenAPIInfo is the info object of the document
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// OpenAPIServer is a server object of the document
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIComponents holds the schemas of the named types
+type OpenAPIComponents struct {
+	Schemas map[string]*OpenAPISchema `json:"schemas,omitempty"`
+}
+
+// OpenAPIOperation documents a method of a path
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+	Parameters  []OpenAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter is a path or query parameter
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required,omitempty"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPIRequestBody documents the json body of a request
+type OpenAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse documents a response of an operation
+type OpenAPIResponse struct {
+	Description string                      `json:"description"`
+	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType holds the schema of a content type
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+// OpenAPISchema is the subset of the JSON schema used by OpenAPI 3.0
+type OpenAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Pattern              string                    `json:"pattern,omitempty"`
+	Minimum              *float64                  `json:"minimum,omitempty"`
+	Nullable             bool                      `json:"nullable,omitempty"`
+	Items                *OpenAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*OpenAPISchema `json:"properties,omitempty"`
+	AdditionalProperties *OpenAPISchema            `json:"additionalProperties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+}
+
+// OpenAPI generate the document of the routes which are not hidden, the host
+// routes only with OpenAPIConfig.Host. :name params become {name} and the
+// catch-all *name becomes {name}
+func (engine *Engine) OpenAPI(config OpenAPIConfig) *OpenAPIDocument {
+	if config.Title == "" {
+		config.Title = "gee"
+	}
+	if config.Version == "" {
+		config.Version = "0.0.0"
+	}
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    OpenAPIInfo{Title: config.Title, Version: config.Version, Description: config.Description},
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	for _, server := range config.Servers {
+		doc.Servers = append(doc.Servers, OpenAPIServer{URL: server})
+	}
+	gen := &schemaGenerator{schemas: make(map[string]*OpenAPISchema), names: make(map[reflect.Type]string)}
+	// 全局路由在前，同一个 path 上 host 的路由覆盖全局的
+	var global, host []*Route
+	for _, route := range engine.routes {
+		switch route.Host {
+		case "":
+			global = append(global, route)
+		case config.Host:
+			host = append(host, route)
+		}
+	}
+	for _, route := range append(global, host...) {
+		if route.Meta.Hidden {
+			continue
+		}
+		for _, pattern := range expandOptional(route.Path) {
+			path, params := openAPIPath(pattern)
+			op := gen.operation(route, params)
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*OpenAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = op
+		}
+	}
+	doc.Components.Schemas = gen.schemas
+	return doc
+}
+
+// JSON encode the document with indentation
+func (doc *OpenAPIDocument) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// WriteFile write the document to name, eg. from a test:
+//
+//	r.OpenAPI(gee.OpenAPIConfig{Title: "api"}).WriteFile("openapi.json")
+func (doc *OpenAPIDocument) WriteFile(name string) error {
+	data, err := doc.JSON()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+// ServeOpenAPI serve the document of the engine as json on path, the route is hidden
+func (g *RouterGroup) ServeOpenAPI(path string, config OpenAPIConfig) *Route {
+	engine := g.engine
+	route := g.GET(path, func(c *Context) {
+		c.JSON(http.StatusOK, engine.OpenAPI(config))
+	})
+	route.Meta.Hidden = true
+	return route
+}
+
+// openAPIPath convert a gee pattern, the params are returned with their schema
+func openAPIPath(pattern string) (string, []OpenAPIParameter) {
+	var params []OpenAPIParameter
+	parts := strings.Split(pattern, "/")
+	for i, part := range parts {
+		switch {
+		case strings.HasPrefix(part, "*"):
+			params = append(params, OpenAPIParameter{Name: part[1:], In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+			parts[i] = "{" + part[1:] + "}"
+		case strings.Contains(part, ":"):
+			var b strings.Builder
+			for part != "" {
+				start := strings.IndexByte(part, ':')
+				if start < 0 {
+					b.WriteString(part)
+					break
+				}
+				b.WriteString(part[:start])
+				end := paramEnd(part, start)
+				name, constraint := splitParam(part[start:end])
+				params = append(params, OpenAPIParameter{Name: name, In: "path", Required: true, Schema: constraintSchema(constraint)})
+				b.WriteString("{" + name + "}")
+				part = part[end:]
+			}
+			parts[i] = b.String()
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// constraintSchema returns the schema of a constrained param
+func constraintSchema(constraint string) *OpenAPISchema {
+	switch constraint {
+	case "":
+		return &OpenAPISchema{Type: "string"}
+	case "int":
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case "uint":
+		zero := 0.0
+		return &OpenAPISchema{Type: "integer", Format: "int64", Minimum: &zero}
+	case "uuid":
+		return &OpenAPISchema{Type: "string", Format: "uuid"}
+	case "alpha":
+		return &OpenAPISchema{Type: "string", Pattern: "^[A-Za-z]+$"}
+	}
+	return &OpenAPISchema{Type: "string", Pattern: "^(?:" + constraint + ")$"}
+}
+
+// schemaGenerator reflects the schemas, named struct types go to the components
+type schemaGenerator struct {
+	schemas map[string]*OpenAPISchema
+	names   map[reflect.Type]string
+}
+
+func (gen *schemaGenerator) operation(route *Route, params []OpenAPIParameter) *OpenAPIOperation {
+	meta := route.Meta
+	op := &OpenAPIOperation{
+		OperationID: meta.OperationID,
+		Summary:     meta.Summary,
+		Description: meta.Description,
+		Tags:        meta.Tags,
+		Deprecated:  meta.Deprecated,
+		Parameters:  params,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+	if meta.Query != nil {
+		op.Parameters = append(op.Parameters, gen.queryParams(reflect.TypeOf(meta.Query))...)
+	}
+	if meta.Request != nil {
+		op.RequestBody = &OpenAPIRequestBody{
+			Required: true,
+			Content:  map[string]OpenAPIMediaType{"application/json": {Schema: gen.schema(reflect.TypeOf(meta.Request))}},
+		}
+	}
+	codes := make([]int, 0, len(meta.Responses))
+	for code := range meta.Responses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		res := &OpenAPIResponse{Description: http.StatusText(code)}
+		if body := meta.Responses[code]; body != nil {
+			contentType := "application/json"
+			if _, ok := body.(Problem); ok {
+				contentType = "application/problem+json"
+			}
+			res.Content = map[string]OpenAPIMediaType{contentType: {Schema: gen.schema(reflect.TypeOf(body))}}
+		}
+		op.Responses[strconv.Itoa(code)] = res
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["default"] = &OpenAPIResponse{Description: "Response"}
+	}
+	return op
+}
+
+// queryParams returns the form tagged fields of a struct as query parameters
+func (gen *schemaGenerator) queryParams(t reflect.Type) []OpenAPIParameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []OpenAPIParameter
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("form"), ",")[0]
+		if !f.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		params = append(params, OpenAPIParameter{
+			Name:     name,
+			In:       "query",
+			Required: isRequired(f),
+			Schema:   gen.schema(f.Type),
+		})
+	}
+	return params
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema reflect the schema of t
+func (gen *schemaGenerator) schema(t reflect.Type) *OpenAPISchema {
+	if t.Kind() == reflect.Ptr {
+		s := gen.schema(t.Elem())
+		if s.Ref == "" {
+			s.Nullable = true
+		}
+		return s
+	}
+	if t == timeType {
+		return &OpenAPISchema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &OpenAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &OpenAPISchema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &OpenAPISchema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &OpenAPISchema{Type: "integer", Minimum: &zero}
+	case reflect.Float32:
+		return &OpenAPISchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &OpenAPISchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &OpenAPISchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &OpenAPISchema{Type: "string", Format: "byte"}
+		}
+		return &OpenAPISchema{Type: "array", Items: gen.schema(t.Elem())}
+	case reflect.Map:
+		return &OpenAPISchema{Type: "object", AdditionalProperties: gen.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return gen.structSchema(t)
+		}
+		return &OpenAPISchema{Ref: "#/components/schemas/" + gen.define(t)}
+	}
+	// interface 等无法确定的类型
+	return &OpenAPISchema{}
+}
+
+// define add the named struct t to the components, the name is made unique
+// with the package when two packages have a type of the same name
+func (gen *schemaGenerator) define(t reflect.Type) string {
+	if name, ok := gen.names[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if _, taken := gen.schemas[name]; taken {
+		name = strings.ReplaceAll(t.PkgPath(), "/", ".") + "." + name
+	}
+	gen.names[t] = name
+	// 先占位，递归类型引用自身时不会无限展开
+	gen.schemas[name] = &OpenAPISchema{}
+	*gen.schemas[name] = *gen.structSchema(t)
+	return name
+}
+
+// structSchema reflect the json fields of a struct, embedded structs are flattened
+func (gen *schemaGenerator) structSchema(t reflect.Type) *OpenAPISchema {
+	s := &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema)}
+	gen.addFields(s, t)
+	return s
+}
+
+func (gen *schemaGenerator) addFields(s *OpenAPISchema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				gen.addFields(s, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = gen.schema(f.Type)
+		if isRequired(f) {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// isRequired reports whether the field is tagged binding:"required"
+func isRequired(f reflect.StructField) bool {
+	for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+		if rule == "required" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package gee_test
+
+import (
+	"encoding/json"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type apiUser struct {
+	ID      int64      `json:"id"`
+	Name    string     `json:"name" binding:"required"`
+	Email   *string    `json:"email,omitempty"`
+	Tags    []string   `json:"tags"`
+	Created time.Time  `json:"created"`
+	Friends []*apiUser `json:"friends"`
+	secret  string
+}
+
+type apiCreateUser struct {
+	Name  string `json:"name" binding:"required"`
+	Admin bool   `json:"-"`
+}
+
+type apiListQuery struct {
+	Page  int    `form:"page"`
+	Order string `form:"order" binding:"required"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	r := gee.New()
+	v1 := r.Group("/v1")
+	v1.GET("/users", nil).Doc(gee.RouteMeta{
+		Summary:   "List users",
+		Tags:      []string{"users"},
+		Query:     apiListQuery{},
+		Responses: map[int]interface{}{200: []apiUser{}},
+	})
+	v1.POST("/users", nil).Doc(gee.RouteMeta{
+		OperationID: "createUser",
+		Request:     apiCreateUser{},
+		Responses:   map[int]interface{}{201: apiUser{}, 400: gee.Problem{}, 204: nil},
+	})
+	v1.GET("/users/:id<int>/files/*path", nil)
+	v1.GET("/archive/:year/:month?", nil)
+	v1.GET("/hidden", nil).Meta.Hidden = true
+	r.ServeOpenAPI("/openapi.json", gee.OpenAPIConfig{Title: "users", Version: "1.0.0"})
+
+	doc := r.OpenAPI(gee.OpenAPIConfig{Title: "users", Version: "1.0.0", Servers: []string{"https://api.example.com"}})
+	var paths []string
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	for _, want := range []string{"/v1/users", "/v1/users/{id}/files/{path}", "/v1/archive/{year}", "/v1/archive/{year}/{month}"} {
+		if doc.Paths[want] == nil {
+			t.Errorf("path %s missing from %v", want, paths)
+		}
+	}
+	if len(doc.Paths) != 4 {
+		t.Errorf("paths = %v, hidden routes must be left out", paths)
+	}
+
+	list := doc.Paths["/v1/users"]["get"]
+	if list.Summary != "List users" || len(list.Parameters) != 2 || list.Parameters[1].Name != "order" || !list.Parameters[1].Required {
+		t.Errorf("list operation %+v", list)
+	}
+	if items := list.Responses["200"].Content["application/json"].Schema.Items; items.Ref != "#/components/schemas/apiUser" {
+		t.Errorf("list response items %+v", items)
+	}
+	create := doc.Paths["/v1/users"]["post"]
+	if create.OperationID != "createUser" || create.RequestBody == nil || create.Responses["204"].Content != nil ||
+		create.Responses["400"].Content["application/problem+json"].Schema == nil {
+		t.Errorf("create operation %+v", create)
+	}
+	params := doc.Paths["/v1/users/{id}/files/{path}"]["get"].Parameters
+	if len(params) != 2 || params[0].Schema.Type != "integer" || params[1].Name != "path" {
+		t.Errorf("path params %+v", params)
+	}
+
+	user := doc.Components.Schemas["apiUser"]
+	var props []string
+	for name := range user.Properties {
+		props = append(props, name)
+	}
+	if len(user.Properties) != 6 || !reflect.DeepEqual(user.Required, []string{"name"}) {
+		t.Errorf("apiUser properties %v, required %v", props, user.Required)
+	}
+	if !user.Properties["email"].Nullable || user.Properties["created"].Format != "date-time" ||
+		user.Properties["friends"].Items.Ref != "#/components/schemas/apiUser" {
+		t.Errorf("apiUser schema %+v", user.Properties)
+	}
+	if _, ok := doc.Components.Schemas["apiCreateUser"].Properties["Admin"]; ok {
+		t.Error(`json:"-" field is documented`)
+	}
+
+	// 导出到文件，和路由返回的文档一致
+	name := filepath.Join(t.TempDir(), "openapi.json")
+	if err := doc.WriteFile(name); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported map[string]interface{}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatal(err)
+	}
+	if exported["openapi"] != "3.0.3" {
+		t.Errorf("exported %v", exported["openapi"])
+	}
+	geetest.New(t, r).GET("/openapi.json").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectJSON("info.title", "users").
+		ExpectJSON("paths./v1/users.post.operationId", "createUser")
+}
+
+// 不同 host 上相同的 pattern 不会互相覆盖
+func TestOpenAPIHosts(t *testing.T) {
+	r := gee.New()
+	r.GET("/users", nil).Doc(gee.RouteMeta{Summary: "global users"})
+	r.GET("/health", nil).Doc(gee.RouteMeta{Summary: "health"})
+	r.Host("api.example.com").GET("/users", nil).Doc(gee.RouteMeta{Summary: "api users"})
+	r.Host("admin.example.com").GET("/users", nil).Doc(gee.RouteMeta{Summary: "admin users"})
+	admin := r.Host("admin.example.com")
+	admin.GET("/audit", nil)
+
+	summaries := func(doc *gee.OpenAPIDocument) map[string]string {
+		got := make(map[string]string)
+		for path, item := range doc.Paths {
+			got[path] = item["get"].Summary
+		}
+		return got
+	}
+	cases := []struct {
+		host string
+		want map[string]string
+	}{
+		{"", map[string]string{"/users": "global users", "/health": "health"}},
+		{"api.example.com", map[string]string{"/users": "api users", "/health": "health"}},
+		{"admin.example.com", map[string]string{"/users": "admin users", "/health": "health", "/audit": ""}},
+	}
+	for _, tc := range cases {
+		got := summaries(r.OpenAPI(gee.OpenAPIConfig{Host: tc.host}))
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("host %q: paths = %v, want %v", tc.host, got, tc.want)
+		}
+	}
+}
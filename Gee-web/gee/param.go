@@ -0,0 +1,197 @@
+package gee
+
+import (
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// paramConstraints are the named constraints usable in patterns, eg. /user/:id<int>,
+// any other constraint is compiled as a regexp matching the whole segment
+var paramConstraints = map[string]func(string) bool{
+	"int": func(s string) bool {
+		_, err := strconv.ParseInt(s, 10, 64)
+		return err == nil
+	},
+	"uint": func(s string) bool {
+		_, err := strconv.ParseUint(s, 10, 64)
+		return err == nil
+	},
+	"uuid": func(s string) bool {
+		_, err := ParseUUID(s)
+		return err == nil
+	},
+	"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+}
+
+// constraintPatterns are the regexps of the named constraints,
+// used when a param shares its segment with static text
+var constraintPatterns = map[string]string{
+	"int":   `[-+]?[0-9]+`,
+	"uint":  `[0-9]+`,
+	"uuid":  `[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	"alpha": `[a-zA-Z]+`,
+}
+
+// splitParam split a param part like :id<int> or :page? into its name and constraint
+func splitParam(part string) (name string, constraint string) {
+	name = strings.TrimSuffix(part[1:], "?")
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// isOptional reports whether part is an optional param like :page?
+func isOptional(part string) bool {
+	return len(part) > 2 && part[0] == ':' && strings.HasSuffix(part, "?")
+}
+
+// segment matches a part mixing static text and params, eg. :name.:ext or v:version
+type segment struct {
+	re    *regexp.Regexp
+	names []string
+}
+
+// segments caches the compiled segments by part
+var segments sync.Map
+
+// parseSegment returns the segment of a mixed part, nil for static parts and
+// parts holding a single param. Params are greedy, so in :name.:ext the last dot
+// separates name and ext
+func parseSegment(part string) *segment {
+	if v, ok := segments.Load(part); ok {
+		return v.(*segment)
+	}
+	seg := compileSegment(part)
+	segments.Store(part, seg)
+	return seg
+}
+
+func compileSegment(part string) *segment {
+	i := strings.IndexByte(part, ':')
+	if i < 0 || strings.HasPrefix(part, "*") {
+		return nil
+	}
+	if i == 0 && paramEnd(part, 0) == len(part) {
+		return nil
+	}
+	var expr strings.Builder
+	var names []string
+	expr.WriteString("^")
+	for part != "" {
+		i := strings.IndexByte(part, ':')
+		if i < 0 {
+			expr.WriteString(regexp.QuoteMeta(part))
+			break
+		}
+		expr.WriteString(regexp.QuoteMeta(part[:i]))
+		end := paramEnd(part, i)
+		name, constraint := splitParam(part[i:end])
+		if name == "" {
+			panic("gee: param without a name in segment " + part)
+		}
+		if p, ok := constraintPatterns[constraint]; ok {
+			constraint = p
+		} else if constraint == "" {
+			constraint = ".+"
+		}
+		// 用命名分组取值，约束里的分组不会打乱顺序
+		fmt.Fprintf(&expr, "(?P<p%d>%s)", len(names), constraint)
+		names = append(names, name)
+		part = part[end:]
+	}
+	expr.WriteString("$")
+	return &segment{re: regexp.MustCompile(expr.String()), names: names}
+}
+
+// paramEnd returns the end of the param starting at part[i] == ':'
+func paramEnd(part string, i int) int {
+	j := i + 1
+	for j < len(part) && (part[j] == '_' || 'a' <= part[j] && part[j] <= 'z' ||
+		'A' <= part[j] && part[j] <= 'Z' || '0' <= part[j] && part[j] <= '9') {
+		j++
+	}
+	if j < len(part) && part[j] == '<' {
+		if k := strings.IndexByte(part[j:], '>'); k >= 0 {
+			j += k + 1
+		}
+	}
+	if j < len(part) && part[j] == '?' {
+		j++
+	}
+	return j
+}
+
+// match returns the params of part, or nil when it doesn't match
+func (seg *segment) match(part string) map[string]string {
+	values := seg.re.FindStringSubmatch(part)
+	if values == nil {
+		return nil
+	}
+	params := make(map[string]string, len(seg.names))
+	for i, name := range seg.names {
+		params[name] = values[seg.re.SubexpIndex(fmt.Sprintf("p%d", i))]
+	}
+	return params
+}
+
+// compileConstraint returns the matcher of a constraint, it panics on a bad regexp
+func compileConstraint(constraint string) func(string) bool {
+	if constraint == "" {
+		return nil
+	}
+	if match, ok := paramConstraints[constraint]; ok {
+		return match
+	}
+	return regexp.MustCompile(`^(?:` + constraint + `)$`).MatchString
+}
+
+// ParamInt returns the param key as an int
+func (c *Context) ParamInt(key string) (int, error) {
+	return strconv.Atoi(c.Param(key))
+}
+
+// ParamInt64 returns the param key as an int64
+func (c *Context) ParamInt64(key string) (int64, error) {
+	return strconv.ParseInt(c.Param(key), 10, 64)
+}
+
+// ParamUUID returns the param key as an UUID
+func (c *Context) ParamUUID(key string) (UUID, error) {
+	return ParseUUID(c.Param(key))
+}
+
+// UUID is a RFC 4122 UUID
+type UUID [16]byte
+
+// ParseUUID parse the canonical form xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("gee: invalid UUID %q", s)
+	}
+	src := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(src)); err != nil {
+		return u, fmt.Errorf("gee: invalid UUID %q", s)
+	}
+	return u, nil
+}
+
+// String returns the canonical lower case form
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
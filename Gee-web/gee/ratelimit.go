@@ -0,0 +1,106 @@
+package gee
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimitSweep is the interval of the removal of the idle buckets
+const rateLimitSweep = time.Minute
+
+// RateLimitConfig configures the RateLimit middleware
+type RateLimitConfig struct {
+	// Rate is the number of requests allowed per second and key, on average
+	Rate float64
+	// Burst is the number of requests allowed at once, default 1
+	Burst int
+	// KeyFunc returns the key the requests are counted by, default c.ClientIP()
+	KeyFunc func(c *Context) string
+	// Skipper skips the limit when it returns true
+	Skipper func(c *Context) bool
+	// Handler writes the response above the limit, default 429 Too Many Requests;
+	// the Retry-After header is already set
+	Handler HandlerFunc
+}
+
+// tokenBucket holds up to burst tokens, refilled at rate per second
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimit middleware allows rate requests per second and client IP, with bursts of burst requests:
+//
+//	api.Use(gee.RateLimit(10, 20))
+//
+// The client IP is only right behind a proxy trusted with Engine.SetTrustedProxies
+func RateLimit(rate float64, burst int) HandlerFunc {
+	return RateLimitWithConfig(RateLimitConfig{Rate: rate, Burst: burst})
+}
+
+// RateLimitWithConfig returns a RateLimit middleware with config
+func RateLimitWithConfig(config RateLimitConfig) HandlerFunc {
+	if config.Rate <= 0 {
+		panic("gee: rate limit needs a positive rate")
+	}
+	if config.Burst <= 0 {
+		config.Burst = 1
+	}
+	if config.KeyFunc == nil {
+		config.KeyFunc = func(c *Context) string { return c.ClientIP() }
+	}
+	if config.Handler == nil {
+		config.Handler = func(c *Context) {
+			c.String(http.StatusTooManyRequests, "429 TOO MANY REQUESTS\n")
+		}
+	}
+	burst := float64(config.Burst)
+	var (
+		mu        sync.Mutex
+		buckets   = make(map[string]*tokenBucket)
+		lastSweep = time.Now()
+	)
+	return func(c *Context) {
+		if config.Skipper != nil && config.Skipper(c) {
+			c.Next()
+			return
+		}
+		key := config.KeyFunc(c)
+		now := time.Now()
+
+		mu.Lock()
+		// 定期删除已经补满的桶，它们和新建的桶没有区别
+		if now.Sub(lastSweep) > rateLimitSweep {
+			for k, b := range buckets {
+				if b.tokens+now.Sub(b.last).Seconds()*config.Rate >= burst {
+					delete(buckets, k)
+				}
+			}
+			lastSweep = now
+		}
+		b, ok := buckets[key]
+		if !ok {
+			b = &tokenBucket{tokens: burst, last: now}
+			buckets[key] = b
+		}
+		b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*config.Rate)
+		b.last = now
+		allowed := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		wait := (1 - b.tokens) / config.Rate
+		mu.Unlock()
+
+		if !allowed {
+			c.SetHeader("Retry-After", strconv.Itoa(int(math.Ceil(wait))))
+			c.Abort()
+			config.Handler(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,57 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"testing"
+)
+
+func TestRateLimit(t *testing.T) {
+	r := gee.New()
+	r.SetTrustedProxies("10.0.0.1")
+	r.Use(gee.RateLimit(0.5, 2))
+	r.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	client := geetest.New(t, r)
+
+	for i := 0; i < 2; i++ {
+		client.GET("/").RemoteAddr("203.0.113.1:1000").Do().ExpectStatus(http.StatusOK)
+	}
+	client.GET("/").RemoteAddr("203.0.113.1:1001").Do().
+		ExpectStatus(http.StatusTooManyRequests).
+		ExpectHeader("Retry-After", "2")
+	// 每个客户端 IP 一个桶，经过可信代理的按 X-Forwarded-For 计数
+	client.GET("/").RemoteAddr("203.0.113.2:1000").Do().ExpectStatus(http.StatusOK)
+	for i := 0; i < 2; i++ {
+		client.GET("/").RemoteAddr("10.0.0.1:80").Header("X-Forwarded-For", "198.51.100.1").Do().
+			ExpectStatus(http.StatusOK)
+	}
+	client.GET("/").RemoteAddr("10.0.0.1:80").Header("X-Forwarded-For", "198.51.100.1").Do().
+		ExpectStatus(http.StatusTooManyRequests)
+	client.GET("/").RemoteAddr("10.0.0.1:80").Header("X-Forwarded-For", "198.51.100.2").Do().
+		ExpectStatus(http.StatusOK)
+}
+
+func TestRateLimitWithConfig(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.RateLimitWithConfig(gee.RateLimitConfig{
+		Rate:    1,
+		KeyFunc: func(c *gee.Context) string { return c.Query("user") },
+		Skipper: func(c *gee.Context) bool { return c.Path == "/health" },
+		Handler: func(c *gee.Context) {
+			c.JSON(http.StatusTooManyRequests, gee.H{"error": "slow down"})
+		},
+	}))
+	r.GET("/", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/health", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	client := geetest.New(t, r)
+
+	client.GET("/?user=tom").Do().ExpectStatus(http.StatusOK)
+	client.GET("/?user=tom").Do().
+		ExpectStatus(http.StatusTooManyRequests).
+		ExpectJSON("error", "slow down")
+	client.GET("/?user=jack").Do().ExpectStatus(http.StatusOK)
+	for i := 0; i < 3; i++ {
+		client.GET("/health").Do().ExpectStatus(http.StatusOK)
+	}
+}
@@ -8,13 +8,23 @@ import (
 	"strings"
 )
 
-// Recovery 处理错误中间件
+// Recovery 处理错误中间件，堆栈总是写到日志，debug 模式下也返回给客户端，
+// release 和 test 模式下客户端只收到通用的 500
 func Recovery() HandlerFunc {
 	return func(c *Context) {
 		defer func() {
 			if err := recover(); err != nil {
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
+				stack := trace(message)
+				log.Printf("%s\n\n", stack)
+				c.Abort()
+				if c.Written() {
+					return
+				}
+				if IsDebugging() {
+					c.String(http.StatusInternalServerError, "500 Internal Server Error\n\n%s\n", stack)
+					return
+				}
 				c.Fail(http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
@@ -0,0 +1,35 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecovery(t *testing.T) {
+	defer gee.SetMode(gee.TestMode)
+
+	r := gee.New()
+	r.Use(gee.Recovery())
+	r.GET("/panic", func(c *gee.Context) {
+		panic("secret")
+	})
+	client := geetest.New(t, r)
+
+	// debug 模式下返回 panic 的信息和堆栈
+	gee.SetMode(gee.DebugMode)
+	client.GET("/panic").Do().
+		ExpectStatus(http.StatusInternalServerError).
+		ExpectBodyContains("secret").
+		ExpectBodyContains("Traceback")
+
+	for _, mode := range []string{gee.ReleaseMode, gee.TestMode} {
+		gee.SetMode(mode)
+		res := client.GET("/panic").Do().ExpectStatus(http.StatusInternalServerError)
+		if body := res.Body.String(); strings.Contains(body, "secret") || strings.Contains(body, "Traceback") {
+			t.Errorf("%s mode: body leaks the panic: %q", mode, body)
+		}
+	}
+}
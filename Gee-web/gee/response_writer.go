@@ -0,0 +1,162 @@
+package gee
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps http.ResponseWriter and remembers
+// the status code and the number of bytes written
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader only forwards the first call, later calls are ignored
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.status = code
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+// Written reports whether the header has been sent to the client
+func (w *responseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+// Flush implements http.Flusher
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gee: the ResponseWriter doesn't support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap is used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// discardWriter drops the response of a handler whose request was already answered
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+// bufferWriter keeps the response in memory, so middlewares can inspect it before it's sent
+type bufferWriter struct {
+	header http.Header
+	buf    bytes.Buffer
+	code   int
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferWriter) Write(data []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.buf.Write(data)
+}
+
+func (w *bufferWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+// nextBuffered run the rest of the chain writing to a bufferWriter, the header
+// of the buffer starts as a copy of the current one; nothing is sent to the client
+func (c *Context) nextBuffered() *bufferWriter {
+	bw := &bufferWriter{header: c.Writer.Header().Clone()}
+	origWriter, origW := c.writer, c.Writer
+	c.writer = newResponseWriter(bw)
+	c.Writer = c.writer
+	defer func() {
+		c.writer, c.Writer = origWriter, origW
+	}()
+	c.Next()
+	return bw
+}
+
+// flushTo send the buffered response to the writer of c, with body instead of the buffer
+func (w *bufferWriter) flushTo(c *Context, body []byte) {
+	header := c.Writer.Header()
+	for k := range header {
+		if _, ok := w.header[k]; !ok {
+			delete(header, k)
+		}
+	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.code == 0 {
+		return
+	}
+	c.Status(w.code)
+	c.Writer.Write(body)
+}
+
+// BufferedResponse is the response of the rest of the chain run by NextBuffered
+type BufferedResponse struct {
+	// Status is 0 when the chain wrote nothing
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// NextBuffered run the rest of the chain like Next but keeps the response in
+// memory, nothing is sent to the client before WriteBuffered
+func (c *Context) NextBuffered() *BufferedResponse {
+	bw := c.nextBuffered()
+	return &BufferedResponse{Status: bw.code, Header: bw.header, Body: bw.buf.Bytes()}
+}
+
+// WriteBuffered send a response returned by NextBuffered, its header replaces the current one
+func (c *Context) WriteBuffered(res *BufferedResponse) {
+	bw := &bufferWriter{header: res.Header, code: res.Status}
+	bw.flushTo(c, res.Body)
+}
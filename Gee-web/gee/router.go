@@ -2,12 +2,18 @@ package gee
 
 import (
 	"net/http"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
 )
 
 type router struct {
 	roots    map[string]*node
-	handlers map[string]HandlerFunc
+	handlers map[string][]HandlerFunc
+	// shapes map the patterns without param names to the registered pattern,
+	// eg. GET-/user/: for /user/:id, to detect routes matching the same paths
+	shapes map[string]string
 }
 
 // roots key eg, roots['GET'] roots['POST']
@@ -16,11 +22,14 @@ type router struct {
 func newRouter() *router {
 	return &router{
 		roots:    make(map[string]*node),
-		handlers: make(map[string]HandlerFunc),
+		handlers: make(map[string][]HandlerFunc),
+		shapes:   make(map[string]string),
 	}
 }
 
 // parsePattern 解析pattern /p/go/doc 转为parts["p","go","doc"]
+// params may carry a constraint which can't contain a slash, eg. /p/:id<int>
+// a trailing slash is kept as an empty last part, /p/go/ 转为parts["p","go",""], / 转为parts[""]
 func parsePattern(pattern string) []string {
 	vs := strings.Split(pattern, "/")
 	parts := make([]string, 0)
@@ -28,31 +37,100 @@ func parsePattern(pattern string) []string {
 		if item != "" {
 			parts = append(parts, item)
 			if item[0] == '*' {
-				break
+				return parts
 			}
 		}
 	}
+	if strings.HasSuffix(pattern, "/") {
+		parts = append(parts, "")
+	}
 	return parts
 }
 
-// addRoute add route to r.roots and r.handlers
-func (r *router) addRoute(method string, pattern string, handler HandlerFunc) {
+// cleanPath is path.Clean that keeps the trailing slash
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// expandOptional expand the optional params of pattern, they can only end it,
+// so a run of them is dropped from the right one by one:
+// /list/:page? 转为 ["/list", "/list/:page"]，/a/:x?/:y? 转为 ["/a", "/a/:x", "/a/:x/:y"]
+func expandOptional(pattern string) []string {
+	items := strings.Split(pattern, "/")[1:]
+	first := len(items)
+	for first > 0 && isOptional(items[first-1]) {
+		first--
+	}
+	for _, item := range items[:first] {
+		if isOptional(item) {
+			panic("gee: optional param " + item + " must be at the end of " + pattern)
+		}
+	}
+	patterns := []string{"/" + strings.Join(items[:first], "/")}
+	for i := first; i < len(items); i++ {
+		items[i] = strings.TrimSuffix(items[i], "?")
+		patterns = append(patterns, "/"+strings.Join(items[:i+1], "/"))
+	}
+	return patterns
+}
+
+// patternShape returns pattern without the param names, two routes with
+// the same shape match the same paths, /user/:id 转为 /user/:
+func patternShape(pattern string) string {
 	parts := parsePattern(pattern)
+	for i, part := range parts {
+		if seg := parseSegment(part); seg != nil {
+			parts[i] = seg.re.String()
+			continue
+		}
+		switch {
+		case strings.HasPrefix(part, ":"):
+			_, constraint := splitParam(part)
+			parts[i] = ":" + constraint
+		case strings.HasPrefix(part, "*"):
+			parts[i] = "*"
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+// addRoute add route to r.roots and r.handlers, a pattern with
+// optional params is added once for each length. It panics when another
+// registered pattern matches the same paths
+func (r *router) addRoute(method string, pattern string, handlers ...HandlerFunc) {
+	patterns := []string{pattern}
+	if strings.Contains(pattern, "?") {
+		patterns = expandOptional(pattern)
+	}
 	if _, ok := r.roots[method]; !ok {
 		r.roots[method] = &node{}
 	}
-	// insert节点
-	r.roots[method].insert(pattern, parts, 0)
-	// save HandlerFunc
-	key := method + "-" + pattern
-	r.handlers[key] = handler
+	for _, p := range patterns {
+		if other, ok := r.shapes[method+"-"+patternShape(p)]; ok && other != pattern {
+			panic("gee: route " + method + " " + pattern + " conflicts with " + other)
+		}
+	}
+	for _, p := range patterns {
+		r.shapes[method+"-"+patternShape(p)] = pattern
+		// insert节点
+		r.roots[method].insert(p, parsePattern(p), 0).route = pattern
+		// save HandlerFunc
+		key := method + "-" + p
+		r.handlers[key] = handlers
+	}
 }
 
-// getRoute get route
+// getRoute get route, the empty segments of path are skipped
 func (r *router) getRoute(method string, path string) (*node, map[string]string) {
 	//解析请求的path
 	searchParts := parsePattern(path)
-	params := make(map[string]string)
 	// 查找method对应的路由树根节点
 	root, ok := r.roots[method]
 	if !ok {
@@ -61,38 +139,241 @@ func (r *router) getRoute(method string, path string) (*node, map[string]string)
 	// 根据root查找pattern
 	n := root.search(searchParts, 0)
 	if n != nil {
-		//解析注册路由时的pattern
-		parts := parsePattern(n.pattern)
-		//封装params参数
-		for index, part := range parts {
-			if part[0] == ':' {
-				params[part[1:]] = searchParts[index]
+		return n, parseParams(n.pattern, searchParts)
+	}
+	return nil, nil
+}
+
+// parseParams 根据注册路由时的pattern封装params参数
+func parseParams(pattern string, searchParts []string) map[string]string {
+	params := make(map[string]string)
+	for index, part := range parsePattern(pattern) {
+		if seg := parseSegment(part); seg != nil {
+			for name, value := range seg.match(searchParts[index]) {
+				params[name] = value
 			}
-			if part[0] == '*' && len(part) > 1 {
-				params[part[1:]] = strings.Join(searchParts[index:], "/")
+			continue
+		}
+		if strings.HasPrefix(part, ":") {
+			name, _ := splitParam(part)
+			params[name] = searchParts[index]
+		}
+		if strings.HasPrefix(part, "*") && len(part) > 1 {
+			params[part[1:]] = strings.Join(searchParts[index:], "/")
+		}
+	}
+	return params
+}
+
+// findCaseInsensitivePath returns the registered spelling of path
+func (r *router) findCaseInsensitivePath(method string, path string) (string, bool) {
+	root, ok := r.roots[method]
+	if !ok {
+		return "", false
+	}
+	searchParts := parsePattern(path)
+	n := root.searchFold(searchParts, 0)
+	if n == nil {
+		return "", false
+	}
+	parts := parsePattern(n.pattern)
+	fixed := make([]string, 0, len(parts))
+	for index, part := range parts {
+		switch {
+		case strings.Contains(part, ":"):
+			fixed = append(fixed, searchParts[index])
+		case strings.HasPrefix(part, "*"):
+			fixed = append(fixed, searchParts[index:]...)
+		default:
+			fixed = append(fixed, part)
+		}
+	}
+	return "/" + strings.Join(fixed, "/"), true
+}
+
+// redirectPath look for another spelling of the path according to
+// the engine settings, it returns false when nothing should be redirected
+func (r *router) redirectPath(c *Context) (string, bool) {
+	engine := c.engine
+	p := c.Path
+	if engine.RedirectCleanPath {
+		if cleaned := cleanPath(p); cleaned != p {
+			if n, _ := r.getRoute(c.Method, cleaned); n != nil {
+				return cleaned, true
 			}
+			p = cleaned
 		}
-		return n, params
 	}
-	return nil, nil
+	candidates := []string{p}
+	if engine.RedirectTrailingSlash && p != "/" {
+		fixed := p + "/"
+		if strings.HasSuffix(p, "/") {
+			fixed = strings.TrimSuffix(p, "/")
+		}
+		if n, _ := r.getRoute(c.Method, fixed); n != nil {
+			return fixed, true
+		}
+		// /MIXED/CASE/ 也能重定向到 /mixed/case
+		candidates = append(candidates, fixed)
+	}
+	if engine.RedirectCaseInsensitive && p == cleanPath(p) {
+		for _, candidate := range candidates {
+			if fixed, ok := r.findCaseInsensitivePath(c.Method, candidate); ok && fixed != c.Path {
+				return fixed, true
+			}
+		}
+	}
+	return "", false
+}
+
+// redirectHandler redirect to path, 301 for GET and 308 for the other
+// methods so the body and method are kept. A decoded path is escaped again,
+// so a %3F in the request can't become a query and /\evil.com can't leave the site
+func redirectHandler(path string) HandlerFunc {
+	if strings.HasPrefix(path, "/") {
+		path = safeRedirectPath(path)
+	}
+	return func(c *Context) {
+		code := http.StatusMovedPermanently
+		if c.Method != http.MethodGet {
+			code = http.StatusPermanentRedirect
+		}
+		path := path
+		if c.Req.URL.RawQuery != "" {
+			path += "?" + c.Req.URL.RawQuery
+		}
+		c.StatusCode = code
+		http.Redirect(c.Writer, c.Req, path, code)
+	}
 }
 
-// handle to handler context
+// safeRedirectPath escape the decoded path p for a Location header,
+// the leading slashes and backslashes are collapsed to one slash:
+// browsers read //evil.com and /\evil.com as another host
+func safeRedirectPath(p string) string {
+	p = "/" + strings.TrimLeft(p, "/\\")
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// handle to handler context. The exact routes of the matched host are tried
+// first, then the global ones; catch-all routes like /*filepath, eg. an SPA of
+// the host, only come after every other route of both routers. The redirects
+// of both routers only apply when no route matches
 func (r *router) handle(c *Context) {
-	//解析请求，得到路由树的叶子节点，和请求参数params
-	patternNode, parms := r.getRoute(c.Method, c.Path)
-	if patternNode != nil {
-		c.Params = parms
-		key := c.Method + "-" + patternNode.pattern
-		////从handlers中拿出注册时的HandlerFunc处理c
-		//r.handlers[key](c)
-		// 更新:
-		//把当前请求的handler绑定到当前context的handlers中（也就是绑定在中间件之后）
-		c.handlers = append(c.handlers, r.handlers[key])
+	routers := []*router{r}
+	if c.hostRoute != nil {
+		routers = []*router{c.hostRoute.router, r}
+	}
+	nodes := make([]*node, len(routers))
+	params := make([]map[string]string, len(routers))
+	if c.matchesRoutes() {
+		for i, rt := range routers {
+			nodes[i], params[i] = rt.getRoute(c.Method, c.Path)
+		}
+	}
+	for _, catchAll := range []bool{false, true} {
+		for i, n := range nodes {
+			if n != nil && n.isCatchAll() == catchAll {
+				routers[i].use(c, n, params[i])
+				c.Next()
+				return
+			}
+		}
+	}
+	for _, rt := range routers {
+		if rt.redirect(c) {
+			c.Next()
+			return
+		}
+	}
+	var allowed []string
+	if c.matchesRoutes() {
+		for _, rt := range routers {
+			allowed = append(allowed, rt.allowedMethods(c.Method, c.Path)...)
+		}
+	}
+	sort.Strings(allowed)
+	c.methodNotAllowed(allowed)
+}
+
+// matchesRoutes reports whether the path of c may match a route, with
+// RedirectCleanPath a path like /v1//hello is redirected to /v1/hello instead
+func (c *Context) matchesRoutes() bool {
+	return !c.engine.RedirectCleanPath || c.Path == cleanPath(c.Path)
+}
+
+// use append the handlers of the matched node n to the context
+func (r *router) use(c *Context, n *node, params map[string]string) {
+	if c.Params == nil {
+		c.Params = params
 	} else {
-		c.handlers = append(c.handlers, func(c *Context) {
-			c.String(http.StatusNotFound, "404 NOT FOUND: %s\n", c.Path)
-		})
+		// 保留 host 中的参数
+		for k, v := range params {
+			c.Params[k] = v
+		}
+	}
+	c.fullPath = n.route
+	key := c.Method + "-" + n.pattern
+	//把当前请求的handler绑定到当前context的handlers中（也就是绑定在中间件之后）
+	c.handlers = append(c.handlers, r.handlers[key]...)
+}
+
+// redirect append a redirect to another spelling of the path to the context,
+// it returns false when there is none
+func (r *router) redirect(c *Context) bool {
+	if fixed, ok := r.redirectPath(c); ok {
+		c.handlers = append(c.handlers, redirectHandler(fixed))
+		return true
+	}
+	return false
+}
+
+// allowedMethods returns the other methods registered for path
+func (r *router) allowedMethods(method string, path string) []string {
+	var allowed []string
+	for m := range r.roots {
+		if m == method {
+			continue
+		}
+		if n, _ := r.getRoute(m, path); n != nil {
+			allowed = append(allowed, m)
+		}
 	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// methodNotAllowed run the NoMethod handlers with the Allow header when other
+// methods are registered for the path, otherwise the NoRoute handlers
+func (c *Context) methodNotAllowed(allowed []string) {
+	if len(allowed) == 0 || !c.engine.HandleMethodNotAllowed {
+		c.notFound()
+		return
+	}
+	c.SetHeader("Allow", strings.Join(allowed, ", "))
+	c.handlers = append(c.handlers, c.engine.noMethod...)
+	c.handlers = append(c.handlers, defaultErrorHandler(http.StatusMethodNotAllowed, "405 METHOD NOT ALLOWED: %s\n"))
 	c.Next()
 }
+
+// notFound run the NoRoute handlers from inside a handler,
+// eg. when the static handler finds no file
+func (c *Context) notFound() {
+	noRoute := c.engine.noRoute
+	if c.hostRoute != nil && c.hostRoute.noRoute != nil {
+		noRoute = c.hostRoute.noRoute
+	}
+	c.handlers = append(c.handlers, noRoute...)
+	c.handlers = append(c.handlers, defaultErrorHandler(http.StatusNotFound, "404 NOT FOUND: %s\n"))
+	c.Next()
+}
+
+// defaultErrorHandler ends the NoRoute and NoMethod chains,
+// it only writes when the custom handlers wrote nothing
+func defaultErrorHandler(code int, format string) HandlerFunc {
+	return func(c *Context) {
+		if !c.Written() {
+			c.String(code, format, c.Path)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"testing"
+)
+
+func init() {
+	gee.SetMode(gee.TestMode)
+}
+
+func newTestEngine() *gee.Engine {
+	r := gee.New()
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "index")
+	})
+	r.GET("/hello/:name", func(c *gee.Context) {
+		c.String(http.StatusOK, "hello %s", c.Param("name"))
+	})
+	r.GET("/hello/bob", func(c *gee.Context) {
+		c.String(http.StatusOK, "bob")
+	})
+	r.GET("/assets/*filepath", func(c *gee.Context) {
+		c.String(http.StatusOK, "file %s", c.Param("filepath"))
+	})
+	r.GET("/dir/", func(c *gee.Context) {
+		c.String(http.StatusOK, "dir")
+	})
+	r.POST("/login", func(c *gee.Context) {
+		c.String(http.StatusOK, "login %s", c.PostForm("name"))
+	})
+	return r
+}
+
+func TestRouterMatch(t *testing.T) {
+	client := geetest.New(t, newTestEngine())
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/", "index"},
+		{"/hello/tom", "hello tom"},
+		{"/hello/bob", "bob"},
+		{"/assets/css/zhou.css", "file css/zhou.css"},
+		{"/assets/", "file "},
+		{"/dir/", "dir"},
+	}
+	for _, tc := range cases {
+		client.GET(tc.path).Do().ExpectStatus(http.StatusOK).ExpectBody(tc.body)
+	}
+}
+
+func TestRouterRedirect(t *testing.T) {
+	r := newTestEngine()
+	client := geetest.New(t, r)
+	client.GET("/hello/tom/").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/hello/tom")
+	client.GET("/dir").Query("a", "1").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/dir/?a=1")
+	client.GET("/hello//tom").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/hello/tom")
+	client.POST("/login/").Do().
+		ExpectStatus(http.StatusPermanentRedirect).
+		ExpectHeader("Location", "/login")
+	client.GET("/Hello/Tom").Do().ExpectStatus(http.StatusNotFound)
+
+	r.RedirectCaseInsensitive = true
+	client.GET("/HELLO/Tom").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/hello/Tom")
+	// 尾斜杠和大小写一起修正
+	client.GET("/HELLO/Tom/").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/hello/Tom")
+	client.GET("/DIR").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/dir/")
+
+	r.RedirectTrailingSlash = false
+	r.RedirectCleanPath = false
+	client.GET("/hello/tom/").Do().ExpectStatus(http.StatusNotFound)
+	// 不重定向时，空的路径段被跳过
+	client.GET("/hello//tom").Do().ExpectStatus(http.StatusOK).ExpectBody("hello tom")
+}
+
+func TestRouterNoRouteAndNoMethod(t *testing.T) {
+	r := newTestEngine()
+	r.Use(func(c *gee.Context) {
+		c.SetHeader("X-Global", "yes")
+		c.Next()
+	})
+	client := geetest.New(t, r)
+	client.GET("/missing").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectHeader("X-Global", "yes").
+		ExpectBody("404 NOT FOUND: /missing\n")
+	client.GET("/login").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Allow", "POST")
+
+	r.NoRoute(func(c *gee.Context) {
+		c.JSON(http.StatusNotFound, gee.H{"path": c.Path})
+	})
+	r.NoMethod(func(c *gee.Context) {
+		c.SetHeader("X-No-Method", "yes")
+	})
+	client.GET("/missing").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectHeader("X-Global", "yes").
+		ExpectJSON("path", "/missing")
+	client.GET("/login").Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("X-No-Method", "yes")
+}
+
+func TestRouterGroupMiddleware(t *testing.T) {
+	r := gee.New()
+	var calls []string
+	v1 := r.Group("/v1")
+	v1.Use(func(c *gee.Context) {
+		calls = append(calls, "v1")
+		c.Next()
+	})
+	v1.GET("/hello", func(c *gee.Context) {
+		calls = append(calls, "hello")
+	})
+	r.GET("/v2/hello", func(c *gee.Context) {
+		calls = append(calls, "v2")
+	})
+	client := geetest.New(t, r)
+	client.GET("/v1/hello").Do().ExpectStatus(http.StatusOK)
+	client.GET("/v2/hello").Do().ExpectStatus(http.StatusOK)
+	if got := len(calls); got != 3 || calls[0] != "v1" || calls[1] != "hello" || calls[2] != "v2" {
+		t.Errorf("calls = %v", calls)
+	}
+}
+
+func TestRouterConstraints(t *testing.T) {
+	r := gee.New()
+	r.GET("/user/:id<int>", func(c *gee.Context) {
+		id, err := c.ParamInt("id")
+		c.String(http.StatusOK, "id %d %v", id, err)
+	})
+	r.GET("/user/:uuid<uuid>", func(c *gee.Context) {
+		u, _ := c.ParamUUID("uuid")
+		c.String(http.StatusOK, "uuid %s", u)
+	})
+	r.GET("/user/:slug<[a-z-]+>", func(c *gee.Context) {
+		c.String(http.StatusOK, "slug %s", c.Param("slug"))
+	})
+	r.GET("/user/:name", func(c *gee.Context) {
+		c.String(http.StatusOK, "name %s", c.Param("name"))
+	})
+	client := geetest.New(t, r)
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/user/42", "id 42 <nil>"},
+		{"/user/-7", "id -7 <nil>"},
+		{"/user/6BA7B810-9DAD-11D1-80B4-00C04FD430C8", "uuid 6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{"/user/hello-world", "slug hello-world"},
+		{"/user/Tom_1", "name Tom_1"},
+	}
+	for _, tc := range cases {
+		client.GET(tc.path).Do().ExpectStatus(http.StatusOK).ExpectBody(tc.body)
+	}
+
+	strict := gee.New()
+	strict.GET("/item/:id<int>", func(c *gee.Context) {})
+	geetest.New(t, strict).GET("/item/abc").Do().ExpectStatus(http.StatusNotFound)
+}
+
+func TestRouterMixedAndOptionalParams(t *testing.T) {
+	r := gee.New()
+	r.GET("/files/:name.:ext", func(c *gee.Context) {
+		c.String(http.StatusOK, "file %s %s", c.Param("name"), c.Param("ext"))
+	})
+	r.GET("/files/:name", func(c *gee.Context) {
+		c.String(http.StatusOK, "name %s", c.Param("name"))
+	})
+	r.GET("/v:version<int>/api", func(c *gee.Context) {
+		c.String(http.StatusOK, "api v%s", c.Param("version"))
+	})
+	r.GET("/list/:page<int>?", func(c *gee.Context) {
+		c.String(http.StatusOK, "page %q", c.Param("page"))
+	})
+	r.GET("/range/:from?/:to?", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s %q %q", c.FullPath(), c.Param("from"), c.Param("to"))
+	})
+	client := geetest.New(t, r)
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/files/readme.md", "file readme md"},
+		{"/files/archive.tar.gz", "file archive.tar gz"},
+		{"/files/LICENSE", "name LICENSE"},
+		{"/v2/api", "api v2"},
+		{"/list", `page ""`},
+		{"/list/3", `page "3"`},
+		{"/range", `/range/:from?/:to? "" ""`},
+		{"/range/1", `/range/:from?/:to? "1" ""`},
+		{"/range/1/5", `/range/:from?/:to? "1" "5"`},
+	}
+	for _, tc := range cases {
+		client.GET(tc.path).Do().ExpectStatus(http.StatusOK).ExpectBody(tc.body)
+	}
+	client.GET("/vx/api").Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/list/x").Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/range/1/5/9").Do().ExpectStatus(http.StatusNotFound)
+
+	// 同样匹配 /list 或 /list/:page<int> 的路由在注册时 panic
+	for _, pattern := range []string{"/list", "/list/:n<int>", "/range/:a", "/files/:a.:b", "/list/:n?/more"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %s didn't panic", pattern)
+				}
+			}()
+			r.GET(pattern, func(c *gee.Context) {})
+		}()
+	}
+	// 相同的 pattern 可以重新注册
+	r.GET("/list/:page<int>?", func(c *gee.Context) {
+		c.String(http.StatusOK, "again")
+	})
+	client.GET("/list").Do().ExpectBody("again")
+}
+
+func TestRouterHost(t *testing.T) {
+	r := gee.New()
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "default")
+	})
+	api := r.Host("api.example.com")
+	api.Use(func(c *gee.Context) {
+		c.SetHeader("X-Host", "api")
+		c.Next()
+	})
+	api.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "api")
+	})
+	api.NoRoute(func(c *gee.Context) {
+		c.JSON(http.StatusNotFound, gee.H{"error": "no api route"})
+	})
+	tenant := r.Host(":tenant.example.com")
+	tenant.GET("/users/:id", func(c *gee.Context) {
+		c.String(http.StatusOK, "%s %s", c.Param("tenant"), c.Param("id"))
+	})
+	client := geetest.New(t, r)
+	client.GET("/").Header("Host", "api.example.com:8080").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Host", "api").
+		ExpectBody("api")
+	client.GET("/").Header("Host", "www.other.com").Do().
+		ExpectHeader("X-Host", "").
+		ExpectBody("default")
+	client.GET("/users/7").Header("Host", "acme.example.com").Do().ExpectBody("acme 7")
+	client.GET("/users/7").Header("Host", "api.example.com").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectJSON("error", "no api route")
+	client.GET("/users/7").Header("Host", "example.com").Do().
+		ExpectStatus(http.StatusNotFound).
+		ExpectBody("404 NOT FOUND: /users/7\n")
+}
+
+func TestRouterRedirectEscaping(t *testing.T) {
+	r := gee.New()
+	r.GET("/:name", func(c *gee.Context) { c.String(http.StatusOK, c.Param("name")) })
+	client := geetest.New(t, r)
+
+	// /\evil.com 会被浏览器当作 //evil.com
+	client.GET("/%5Cevil.com/").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/evil.com")
+	client.GET("/%5C%5Cevil.com/").Do().ExpectHeader("Location", "/evil.com")
+	// 编码的 ? 不能变成 query
+	client.GET("/a%3Fb/").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/a%3Fb")
+	client.GET("/a%3Fb/?x=1").Do().ExpectHeader("Location", "/a%3Fb?x=1")
+}
+
+func TestRouterHostRedirectOrder(t *testing.T) {
+	r := gee.New()
+	r.GET("/docs", func(c *gee.Context) { c.String(http.StatusOK, "global docs") })
+	docs := r.Host("docs.example.com")
+	docs.GET("/docs/", func(c *gee.Context) { c.String(http.StatusOK, "host docs") })
+	docs.GET("/guide", func(c *gee.Context) { c.String(http.StatusOK, "host guide") })
+	client := geetest.New(t, r)
+
+	// 全局的精确匹配优先于 host 的尾斜杠重定向
+	client.GET("/docs").Header("Host", "docs.example.com").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("global docs")
+	client.GET("/docs/").Header("Host", "docs.example.com").Do().ExpectBody("host docs")
+	client.GET("/guide/").Header("Host", "docs.example.com").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/guide")
+	client.GET("/docs/").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/docs")
+}
@@ -0,0 +1,323 @@
+package gee
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cspNonceKey = "gee/csp-nonce"
+	// cspNoncePlaceholder is replaced by the nonce of the request in ContentSecurityPolicy
+	cspNoncePlaceholder = "{nonce}"
+)
+
+// X-Frame-Options values
+const (
+	FrameDeny       = "DENY"
+	FrameSameOrigin = "SAMEORIGIN"
+)
+
+// Referrer-Policy values
+const (
+	ReferrerNoReferrer                  = "no-referrer"
+	ReferrerSameOrigin                  = "same-origin"
+	ReferrerStrictOrigin                = "strict-origin"
+	ReferrerStrictOriginWhenCrossOrigin = "strict-origin-when-cross-origin"
+)
+
+// SecureConfig configures the Secure middleware, an empty field leaves its header unset
+type SecureConfig struct {
+	// HSTS is the Strict-Transport-Security header, only sent over https
+	HSTS string
+	// FrameOptions is the X-Frame-Options header
+	FrameOptions string
+	// ContentTypeOptions is the X-Content-Type-Options header
+	ContentTypeOptions string
+	// ReferrerPolicy is the Referrer-Policy header
+	ReferrerPolicy string
+	// ContentSecurityPolicy is the Content-Security-Policy header,
+	// {nonce} is replaced by a new nonce on every request
+	ContentSecurityPolicy string
+	// SSLRedirect redirects plain http requests to https
+	SSLRedirect bool
+	// SSLHost is the host of the redirect, default the host of the request
+	// when it's one of AllowedHosts
+	SSLHost string
+	// AllowedHosts are the hosts of the requests SSLRedirect may redirect to
+	// without SSLHost, eg. {"example.com", "www.example.com"}: the client chooses
+	// the Host header, so the other hosts get 400 instead of a redirect
+	AllowedHosts []string
+	// SSLProxyHeaders mark a request as https when one of the headers has the value,
+	// eg. {"X-Forwarded-Proto": "https"}, whoever sends it; Engine.SetTrustedProxies
+	// is the safer way to believe the proxy terminating TLS
+	SSLProxyHeaders map[string]string
+}
+
+// DefaultSecureConfig returns the config used by Secure: HSTS of one year,
+// no framing, nosniff, strict-origin-when-cross-origin and a CSP allowing
+// the resources of the same origin and the scripts carrying the nonce
+func DefaultSecureConfig() SecureConfig {
+	return SecureConfig{
+		HSTS:               NewHSTS(365 * 24 * time.Hour).IncludeSubdomains().String(),
+		FrameOptions:       FrameDeny,
+		ContentTypeOptions: "nosniff",
+		ReferrerPolicy:     ReferrerStrictOriginWhenCrossOrigin,
+		ContentSecurityPolicy: NewCSP().
+			DefaultSrc("'self'").
+			ScriptSrc("'self'").Nonce("script-src").
+			ObjectSrc("'none'").
+			BaseURI("'self'").
+			FrameAncestors("'none'").
+			String(),
+	}
+}
+
+// Secure middleware sets the security headers of DefaultSecureConfig
+func Secure() HandlerFunc {
+	return SecureWithConfig(DefaultSecureConfig())
+}
+
+// SecureWithConfig returns a Secure middleware with config.
+// The nonce is available through CSPNonce(c) and the cspNonce template func,
+// which is bound to the Context of c.HTML:
+//
+//	<script nonce="{{ cspNonce }}">...</script>
+func SecureWithConfig(config SecureConfig) HandlerFunc {
+	nonced := strings.Contains(config.ContentSecurityPolicy, cspNoncePlaceholder)
+	if config.SSLRedirect && config.SSLHost == "" && len(config.AllowedHosts) == 0 {
+		warnPrint("Secure: SSLRedirect without SSLHost or AllowedHosts answers 400 to every http request")
+	}
+	return func(c *Context) {
+		https := isHTTPS(c, config.SSLProxyHeaders)
+		if config.SSLRedirect && !https {
+			host := config.SSLHost
+			if host == "" {
+				host = c.Host()
+				if !allowedHost(host, config.AllowedHosts) {
+					c.Abort()
+					c.String(http.StatusBadRequest, "400 BAD REQUEST: host %s is not allowed\n", host)
+					return
+				}
+			}
+			url := *c.Req.URL
+			url.Scheme = "https"
+			url.Host = host
+			url.RawQuery = ""
+			redirectHandler(url.String())(c)
+			c.Abort()
+			return
+		}
+
+		header := c.Writer.Header()
+		if config.HSTS != "" && https {
+			header.Set("Strict-Transport-Security", config.HSTS)
+		}
+		if config.FrameOptions != "" {
+			header.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ContentTypeOptions != "" {
+			header.Set("X-Content-Type-Options", config.ContentTypeOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if config.ContentSecurityPolicy != "" {
+			policy := config.ContentSecurityPolicy
+			if nonced {
+				nonce := newNonce()
+				c.Set(cspNonceKey, nonce)
+				policy = strings.ReplaceAll(policy, cspNoncePlaceholder, nonce)
+			}
+			header.Set("Content-Security-Policy", policy)
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce returns the nonce of the Content-Security-Policy of the request
+func CSPNonce(c *Context) string {
+	v, _ := c.Get(cspNonceKey)
+	nonce, _ := v.(string)
+	return nonce
+}
+
+// cspNonce is the template func, c is the Context rendering the template,
+// nil outside of c.HTML. It also accepts a Context argument
+func cspNonce(c *Context, args ...interface{}) string {
+	if len(args) > 0 {
+		c, _ = args[0].(*Context)
+	}
+	if c == nil {
+		return ""
+	}
+	return CSPNonce(c)
+}
+
+// newNonce returns 16 random bytes in base64url, which needs no escaping in html attributes
+func newNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// isHTTPS reports whether the request came over TLS, directly or through a proxy
+func isHTTPS(c *Context, proxyHeaders map[string]string) bool {
+	if c.Scheme() == "https" {
+		return true
+	}
+	for key, value := range proxyHeaders {
+		if strings.EqualFold(c.Req.Header.Get(key), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// HSTSBuilder builds a Strict-Transport-Security header
+type HSTSBuilder struct {
+	maxAge     time.Duration
+	subdomains bool
+	preload    bool
+}
+
+// NewHSTS create a HSTSBuilder with max-age
+func NewHSTS(maxAge time.Duration) *HSTSBuilder {
+	return &HSTSBuilder{maxAge: maxAge}
+}
+
+// IncludeSubdomains add includeSubDomains
+func (b *HSTSBuilder) IncludeSubdomains() *HSTSBuilder {
+	b.subdomains = true
+	return b
+}
+
+// Preload add preload, it requires includeSubDomains and a max-age of one year
+func (b *HSTSBuilder) Preload() *HSTSBuilder {
+	b.preload = true
+	return b
+}
+
+func (b *HSTSBuilder) String() string {
+	s := "max-age=" + strconv.FormatInt(int64(b.maxAge/time.Second), 10)
+	if b.subdomains {
+		s += "; includeSubDomains"
+	}
+	if b.preload {
+		s += "; preload"
+	}
+	return s
+}
+
+// CSPBuilder builds a Content-Security-Policy header, directives keep their order
+type CSPBuilder struct {
+	names   []string
+	sources map[string][]string
+}
+
+// NewCSP create an empty CSPBuilder
+func NewCSP() *CSPBuilder {
+	return &CSPBuilder{sources: make(map[string][]string)}
+}
+
+// Directive add sources to the directive name, a directive without sources
+// is written alone, eg. upgrade-insecure-requests
+func (b *CSPBuilder) Directive(name string, sources ...string) *CSPBuilder {
+	if _, ok := b.sources[name]; !ok {
+		b.names = append(b.names, name)
+		b.sources[name] = []string{}
+	}
+	b.sources[name] = append(b.sources[name], sources...)
+	return b
+}
+
+// Nonce allow the elements carrying the nonce of the request in directives
+func (b *CSPBuilder) Nonce(directives ...string) *CSPBuilder {
+	for _, name := range directives {
+		b.Directive(name, "'nonce-"+cspNoncePlaceholder+"'")
+	}
+	return b
+}
+
+// DefaultSrc add sources to default-src
+func (b *CSPBuilder) DefaultSrc(sources ...string) *CSPBuilder {
+	return b.Directive("default-src", sources...)
+}
+
+// ScriptSrc add sources to script-src
+func (b *CSPBuilder) ScriptSrc(sources ...string) *CSPBuilder {
+	return b.Directive("script-src", sources...)
+}
+
+// StyleSrc add sources to style-src
+func (b *CSPBuilder) StyleSrc(sources ...string) *CSPBuilder {
+	return b.Directive("style-src", sources...)
+}
+
+// ImgSrc add sources to img-src
+func (b *CSPBuilder) ImgSrc(sources ...string) *CSPBuilder {
+	return b.Directive("img-src", sources...)
+}
+
+// ConnectSrc add sources to connect-src
+func (b *CSPBuilder) ConnectSrc(sources ...string) *CSPBuilder {
+	return b.Directive("connect-src", sources...)
+}
+
+// FontSrc add sources to font-src
+func (b *CSPBuilder) FontSrc(sources ...string) *CSPBuilder {
+	return b.Directive("font-src", sources...)
+}
+
+// ObjectSrc add sources to object-src
+func (b *CSPBuilder) ObjectSrc(sources ...string) *CSPBuilder {
+	return b.Directive("object-src", sources...)
+}
+
+// BaseURI add sources to base-uri
+func (b *CSPBuilder) BaseURI(sources ...string) *CSPBuilder {
+	return b.Directive("base-uri", sources...)
+}
+
+// FormAction add sources to form-action
+func (b *CSPBuilder) FormAction(sources ...string) *CSPBuilder {
+	return b.Directive("form-action", sources...)
+}
+
+// FrameAncestors add sources to frame-ancestors
+func (b *CSPBuilder) FrameAncestors(sources ...string) *CSPBuilder {
+	return b.Directive("frame-ancestors", sources...)
+}
+
+// ReportURI set report-uri
+func (b *CSPBuilder) ReportURI(uri string) *CSPBuilder {
+	return b.Directive("report-uri", uri)
+}
+
+func (b *CSPBuilder) String() string {
+	directives := make([]string, 0, len(b.names))
+	for _, name := range b.names {
+		directives = append(directives, strings.TrimSpace(name+" "+strings.Join(b.sources[name], " ")))
+	}
+	return strings.Join(directives, "; ")
+}
+
+// allowedHost reports whether host, with or without its port, is one of allowed
+func allowedHost(host string, allowed []string) bool {
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, host) || strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
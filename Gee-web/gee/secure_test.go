@@ -0,0 +1,103 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSecure(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.Secure())
+	if err := r.LoadHTMLFS(fstest.MapFS{
+		"page.tmpl": {Data: []byte(`<script nonce="{{ cspNonce }}"></script>`)},
+	}, "*.tmpl"); err != nil {
+		t.Fatal(err)
+	}
+	r.GET("/", func(c *gee.Context) {
+		c.HTML(http.StatusOK, "page.tmpl", nil)
+	})
+	client := geetest.New(t, r)
+
+	res := client.GET("/").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("X-Frame-Options", "DENY").
+		ExpectHeader("X-Content-Type-Options", "nosniff").
+		ExpectHeader("Referrer-Policy", "strict-origin-when-cross-origin").
+		// HSTS 只在 https 请求中发送
+		ExpectHeader("Strict-Transport-Security", "")
+	m := regexp.MustCompile(`nonce="([^"]+)"`).FindStringSubmatch(res.Body.String())
+	if m == nil || m[1] == "" {
+		t.Fatalf("no nonce in %q", res.Body.String())
+	}
+	csp := res.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "script-src 'self' 'nonce-"+m[1]+"'") {
+		t.Errorf("csp %q doesn't allow nonce %q", csp, m[1])
+	}
+	other := client.GET("/").Do().Header().Get("Content-Security-Policy")
+	if other == csp {
+		t.Error("nonce is reused across requests")
+	}
+
+	// 并发渲染时每个页面拿到自己请求的 nonce
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			m := regexp.MustCompile(`nonce="([^"]+)"`).FindStringSubmatch(w.Body.String())
+			if m == nil || !strings.Contains(w.Header().Get("Content-Security-Policy"), "'nonce-"+m[1]+"'") {
+				t.Errorf("nonce of %q isn't the one of the policy", w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSecureSSLRedirect(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.SecureWithConfig(gee.SecureConfig{
+		HSTS:            gee.NewHSTS(time.Hour).IncludeSubdomains().Preload().String(),
+		SSLRedirect:     true,
+		AllowedHosts:    []string{"example.com"},
+		SSLProxyHeaders: map[string]string{"X-Forwarded-Proto": "https"},
+		ContentSecurityPolicy: gee.NewCSP().DefaultSrc("'none'").
+			Directive("upgrade-insecure-requests").String(),
+	}))
+	r.GET("/a", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	r.POST("/a", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	client := geetest.New(t, r)
+
+	client.GET("/a").Query("x", "1").Header("Host", "example.com").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "https://example.com/a?x=1")
+	client.POST("/a").Header("Host", "example.com").Do().
+		ExpectStatus(http.StatusPermanentRedirect)
+	client.GET("/a").Header("Host", "example.com:8080").Do().
+		ExpectStatus(http.StatusMovedPermanently)
+	// 不在 AllowedHosts 中的 host 不会被重定向
+	client.GET("/a").Header("Host", "evil.com").Do().
+		ExpectStatus(http.StatusBadRequest).
+		ExpectHeader("Location", "")
+
+	ssl := gee.New()
+	ssl.Use(gee.SecureWithConfig(gee.SecureConfig{SSLRedirect: true, SSLHost: "secure.example.com"}))
+	ssl.GET("/a", func(c *gee.Context) { c.String(http.StatusOK, "ok") })
+	geetest.New(t, ssl).GET("/a").Header("Host", "evil.com").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "https://secure.example.com/a")
+	client.GET("/a").Header("X-Forwarded-Proto", "https").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Strict-Transport-Security", "max-age=3600; includeSubDomains; preload").
+		ExpectHeader("Content-Security-Policy", "default-src 'none'; upgrade-insecure-requests").
+		ExpectHeader("X-Frame-Options", "")
+}
@@ -0,0 +1,385 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticConfig configures the static file handler
+type StaticConfig struct {
+	// Root is the file system to serve, eg. os.DirFS("static") or an embed.FS
+	Root fs.FS
+	// Index is the file served for a directory, default index.html
+	Index string
+	// Browse lists directories which have no index file
+	Browse bool
+	// CacheControl returns the Cache-Control header of a file, nil sets none
+	CacheControl func(name string) string
+	// Precompressed serves the name.br or name.gz sibling of a file
+	// when the client accepts the encoding
+	Precompressed bool
+	// SPA serves the root Index for unknown paths so that client side routes
+	// like /app/settings work. Paths with a file extension, paths of other
+	// routes and requests not accepting text/html don't fall back
+	SPA bool
+	// Exclude lists request path prefixes which never fall back to the
+	// SPA index, eg. /api
+	Exclude []string
+}
+
+// CacheMaxAge returns a CacheControl policy caching every file for d
+func CacheMaxAge(d time.Duration) func(name string) string {
+	value := fmt.Sprintf("public, max-age=%d", int(d.Seconds()))
+	return func(string) string {
+		return value
+	}
+}
+
+// CacheNoCache is a CacheControl policy asking clients to revalidate every time
+func CacheNoCache(string) string {
+	return "no-cache"
+}
+
+// precompressed encodings in order of preference
+var precompressed = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Static serve static file
+func (g *RouterGroup) Static(relativePath string, root string) {
+	g.StaticFS(relativePath, os.DirFS(root))
+}
+
+// StaticFS serve files of fsys, embed.FS is supported
+func (g *RouterGroup) StaticFS(relativePath string, fsys fs.FS) {
+	g.StaticWithConfig(relativePath, StaticConfig{Root: fsys, Precompressed: true})
+}
+
+// StaticWithConfig serve static files with the given config
+func (g *RouterGroup) StaticWithConfig(relativePath string, config StaticConfig) {
+	if config.Browse {
+		warnPrint("directory listing is enabled for %s", path.Join(g.prefix, relativePath))
+	}
+	handler := g.createStaticHandler(config)
+	pattern := path.Join(relativePath, "/*filepath")
+	// register static handler
+	g.GET(pattern, handler)
+	g.HEAD(pattern, handler)
+}
+
+// SPA serve a single page application from fsys, unknown paths get index.html.
+// Registered routes always win over the fallback
+func (g *RouterGroup) SPA(relativePath string, fsys fs.FS, exclude ...string) {
+	g.StaticWithConfig(relativePath, StaticConfig{Root: fsys, Precompressed: true, SPA: true, Exclude: exclude})
+}
+
+// StaticFile serve a single file
+func (g *RouterGroup) StaticFile(relativePath string, file string) {
+	name := filepath.Base(file)
+	s := newStaticServer(StaticConfig{Root: os.DirFS(filepath.Dir(file)), Precompressed: true})
+	handler := func(c *Context) {
+		s.serveFile(c, name)
+	}
+	g.GET(relativePath, handler)
+	g.HEAD(relativePath, handler)
+}
+
+// createStaticHandler create a static handler
+func (g *RouterGroup) createStaticHandler(config StaticConfig) HandlerFunc {
+	s := newStaticServer(config)
+	return func(c *Context) {
+		s.serve(c, c.Param("filepath"))
+	}
+}
+
+type staticServer struct {
+	config StaticConfig
+	// etags of files without modification time, eg. files of embed.FS
+	etags sync.Map
+}
+
+func newStaticServer(config StaticConfig) *staticServer {
+	if config.Index == "" {
+		config.Index = "index.html"
+	}
+	return &staticServer{config: config}
+}
+
+// serve file is the slash separated path relative to the root
+func (s *staticServer) serve(c *Context, file string) {
+	name := strings.TrimPrefix(path.Clean("/"+file), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(s.config.Root, name)
+	if err != nil {
+		s.fallback(c, name)
+		return
+	}
+	isDirPath := file == "" || strings.HasSuffix(file, "/")
+	if !info.IsDir() {
+		if isDirPath {
+			redirectHandler(strings.TrimSuffix(c.Path, "/"))(c)
+			return
+		}
+		s.serveFile(c, name)
+		return
+	}
+	// 目录需要以 / 结尾，页面中的相对路径才能正确解析
+	if !isDirPath {
+		redirectHandler(c.Path + "/")(c)
+		return
+	}
+	index := path.Join(name, s.config.Index)
+	if info, err := fs.Stat(s.config.Root, index); err == nil && !info.IsDir() {
+		s.serveFile(c, index)
+		return
+	}
+	if s.config.Browse {
+		s.listDir(c, name)
+		return
+	}
+	s.fallback(c, name)
+}
+
+// fallback answer a missing file, the SPA index is served for client side routes
+func (s *staticServer) fallback(c *Context, name string) {
+	// 其他方法注册了这个路径，回答 405 而不是 index
+	if allowed := c.otherRoutes(); len(allowed) > 0 {
+		c.methodNotAllowed(allowed)
+		return
+	}
+	if !s.config.SPA || path.Ext(name) != "" || !acceptsHTML(c.Req.Header.Get("Accept")) {
+		c.notFound()
+		return
+	}
+	for _, prefix := range s.config.Exclude {
+		if strings.HasPrefix(c.Path, prefix) {
+			c.notFound()
+			return
+		}
+	}
+	// index 不能被缓存，否则发布新版本后客户端仍引用旧的资源
+	if s.config.CacheControl == nil {
+		c.SetHeader("Cache-Control", "no-cache")
+	}
+	s.serveFile(c, s.config.Index)
+}
+
+// serveFile serve the regular file name, conditional and range requests
+// are handled by http.ServeContent
+func (s *staticServer) serveFile(c *Context, name string) {
+	f, err := s.config.Root.Open(name)
+	if err != nil {
+		c.notFound()
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		c.notFound()
+		return
+	}
+	header := c.Writer.Header()
+	encoding := ""
+	if s.config.Precompressed {
+		if cf, cinfo, enc := s.openPrecompressed(c, name); cf != nil {
+			defer cf.Close()
+			f, info, encoding = cf, cinfo, enc
+		}
+		header.Add("Vary", "Accept-Encoding")
+	}
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			c.Fail(http.StatusInternalServerError, "Internal Server Error")
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	etag, err := s.etag(name, encoding, info, content)
+	if err != nil {
+		c.Fail(http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	header.Set("ETag", etag)
+	if encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	if s.config.CacheControl != nil {
+		if value := s.config.CacheControl(name); value != "" {
+			header.Set("Cache-Control", value)
+		}
+	}
+	c.StatusCode = http.StatusOK
+	// 传入原文件名，Content-Type 由原始扩展名决定
+	http.ServeContent(c.Writer, c.Req, path.Base(name), info.ModTime(), content)
+	c.StatusCode = c.writer.status
+}
+
+// openPrecompressed open the first sibling of name accepted by the client
+func (s *staticServer) openPrecompressed(c *Context, name string) (fs.File, fs.FileInfo, string) {
+	accept := c.Req.Header.Get("Accept-Encoding")
+	if accept == "" {
+		return nil, nil, ""
+	}
+	for _, p := range precompressed {
+		if !acceptsEncoding(accept, p.encoding) {
+			continue
+		}
+		f, err := s.config.Root.Open(name + p.ext)
+		if err != nil {
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			f.Close()
+			continue
+		}
+		return f, info, p.encoding
+	}
+	return nil, nil, ""
+}
+
+// etag build a strong validator from the modification time and size,
+// files without modification time are hashed once
+func (s *staticServer) etag(name, encoding string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	suffix := ""
+	if encoding != "" {
+		suffix = "-" + encoding
+	}
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x%s"`, info.ModTime().UnixNano(), info.Size(), suffix), nil
+	}
+	key := name + suffix
+	if etag, ok := s.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + suffix + `"`
+	s.etags.Store(key, etag)
+	return etag, nil
+}
+
+// listDir write a html listing of the directory name
+func (s *staticServer) listDir(c *Context, name string) {
+	entries, err := fs.ReadDir(s.config.Root, name)
+	if err != nil {
+		c.notFound()
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&buf, "<a href=\"%s\">%s</a>\n", link.String(), html.EscapeString(entryName))
+	}
+	buf.WriteString("</pre>\n")
+	c.SetHeader("Content-Type", "text/html; charset=utf-8")
+	c.Data(http.StatusOK, buf.Bytes())
+}
+
+// otherRoutes returns the methods of the routes matching the path of c,
+// except the static route serving it
+func (c *Context) otherRoutes() []string {
+	var allowed []string
+	routers := []*router{c.engine.router}
+	if c.hostRoute != nil {
+		routers = append(routers, c.hostRoute.router)
+	}
+	if !c.matchesRoutes() {
+		return nil
+	}
+	for _, r := range routers {
+		for method := range r.roots {
+			if n, _ := r.getRoute(method, c.Path); n != nil && n.route != c.fullPath {
+				allowed = append(allowed, method)
+			}
+		}
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// acceptsHTML reports whether the Accept header allows text/html, no header accepts anything
+func acceptsHTML(accept string) bool {
+	if strings.TrimSpace(accept) == "" {
+		return true
+	}
+	for _, item := range strings.Split(accept, ",") {
+		value, params, _ := strings.Cut(item, ";")
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "text/html", "text/*", "*/*":
+		default:
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		if weight, err := strconv.ParseFloat(q, 64); err == nil && weight > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header allows encoding,
+// the item naming encoding takes precedence over *, q=0 refuses it
+func acceptsEncoding(accept, encoding string) bool {
+	star := 0.0
+	for _, item := range strings.Split(accept, ",") {
+		value, params, _ := strings.Cut(item, ";")
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case encoding:
+			return qValue(params) > 0
+		case "*":
+			star = qValue(params)
+		}
+	}
+	return star > 0
+}
+
+// qValue returns the weight of the params of an Accept item, 1 without q
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+			weight, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				return 0
+			}
+			return weight
+		}
+	}
+	return 1
+}
@@ -0,0 +1,220 @@
+package gee_test
+
+import (
+	"gee"
+	"gee/geetest"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSPA(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte("<html>app</html>")},
+		"app.js":     {Data: []byte("console.log(1)")},
+	}
+	r := gee.New()
+	r.POST("/api/users", func(c *gee.Context) { c.String(http.StatusCreated, "created") })
+	r.SPA("/", fsys, "/internal")
+	client := geetest.New(t, r)
+	browser := "text/html,application/xhtml+xml,*/*;q=0.8"
+
+	client.GET("/app/settings").Header("Accept", browser).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Cache-Control", "no-cache").
+		ExpectBody("<html>app</html>")
+	client.GET("/app.js").Do().ExpectStatus(http.StatusOK).ExpectBody("console.log(1)")
+	client.GET("/missing.js").Header("Accept", browser).Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/internal/page").Header("Accept", browser).Do().ExpectStatus(http.StatusNotFound)
+
+	// 其他方法的路由不回退到 index
+	client.GET("/api/users").Header("Accept", browser).Do().
+		ExpectStatus(http.StatusMethodNotAllowed).
+		ExpectHeader("Allow", "POST")
+	client.POST("/api/users").Do().ExpectStatus(http.StatusCreated)
+	// 不接受 html 的请求不回退到 index
+	client.GET("/api/missing").Header("Accept", "application/json").Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/api/missing").Header("Accept", "application/json, text/html;q=0").Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/api/missing").Do().ExpectStatus(http.StatusOK).ExpectBody("<html>app</html>")
+}
+
+// host 的 SPA 不能盖过全局的 API 路由
+func TestHostSPAWithGlobalRoutes(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html": {Data: []byte("<html>app</html>")},
+		"app.js":     {Data: []byte("console.log(1)")},
+	}
+	r := gee.New()
+	r.GET("/api/users", func(c *gee.Context) { c.String(http.StatusOK, "users") })
+	app := r.Host("app.example.com")
+	app.GET("/api/me", func(c *gee.Context) { c.String(http.StatusOK, "me") })
+	app.SPA("/", fsys)
+	client := geetest.New(t, r)
+	browser := "text/html,application/xhtml+xml,*/*;q=0.8"
+
+	client.GET("/api/users").Header("Host", "app.example.com").Header("Accept", browser).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("users")
+	client.GET("/api/me").Header("Host", "app.example.com").Do().ExpectBody("me")
+	client.GET("/app.js").Header("Host", "app.example.com").Do().ExpectBody("console.log(1)")
+	client.GET("/settings").Header("Host", "app.example.com").Header("Accept", browser).Do().
+		ExpectStatus(http.StatusOK).
+		ExpectBody("<html>app</html>")
+	client.GET("/settings").Header("Host", "www.example.com").Header("Accept", browser).Do().
+		ExpectStatus(http.StatusNotFound)
+}
+
+func TestStaticConditional(t *testing.T) {
+	fsys := fstest.MapFS{
+		"hello.txt": {Data: []byte("hello world")},
+		"dated.txt": {Data: []byte("dated"), ModTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+	}
+	r := gee.New()
+	r.StaticFS("/static", fsys)
+	client := geetest.New(t, r)
+
+	for _, name := range []string{"/static/hello.txt", "/static/dated.txt"} {
+		etag := client.GET(name).Do().ExpectStatus(http.StatusOK).Header().Get("ETag")
+		if etag == "" {
+			t.Fatalf("%s: no ETag", name)
+		}
+		client.GET(name).Header("If-None-Match", etag).Do().
+			ExpectStatus(http.StatusNotModified).
+			ExpectBody("")
+		client.GET(name).Header("If-None-Match", `"other"`).Do().ExpectStatus(http.StatusOK)
+	}
+	client.GET("/static/dated.txt").Header("If-Modified-Since", "Tue, 02 Jan 2024 03:04:05 GMT").Do().
+		ExpectStatus(http.StatusNotModified)
+}
+
+func TestStaticRange(t *testing.T) {
+	fsys := fstest.MapFS{"hello.txt": {Data: []byte("hello world")}}
+	r := gee.New()
+	r.StaticFS("/static", fsys)
+	client := geetest.New(t, r)
+
+	client.GET("/static/hello.txt").Header("Range", "bytes=0-4").Do().
+		ExpectStatus(http.StatusPartialContent).
+		ExpectHeader("Content-Range", "bytes 0-4/11").
+		ExpectBody("hello")
+	client.GET("/static/hello.txt").Header("Range", "bytes=6-").Do().
+		ExpectStatus(http.StatusPartialContent).
+		ExpectBody("world")
+	client.GET("/static/hello.txt").Header("Range", "bytes=100-").Do().
+		ExpectStatus(http.StatusRequestedRangeNotSatisfiable).
+		ExpectHeader("Content-Range", "bytes */11")
+}
+
+func TestStaticPrecompressed(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app.js":    {Data: []byte("plain")},
+		"app.js.gz": {Data: []byte("gzipped")},
+		"app.js.br": {Data: []byte("brotli")},
+		"only.css":  {Data: []byte("css")},
+	}
+	r := gee.New()
+	r.StaticFS("/static", fsys)
+	client := geetest.New(t, r)
+
+	cases := []struct {
+		accept   string
+		encoding string
+		body     string
+	}{
+		{"", "", "plain"},
+		{"gzip", "gzip", "gzipped"},
+		{"gzip, br", "br", "brotli"},
+		{"br;q=0, gzip", "gzip", "gzipped"},
+		{"*", "br", "brotli"},
+		{"identity", "", "plain"},
+		{"gzip;q=0, br", "br", "brotli"},
+		{"br;level=5;q=0, gzip;q=0.5", "gzip", "gzipped"},
+		{"*;q=0", "", "plain"},
+		{"br;q=0, *", "gzip", "gzipped"},
+		{"*;q=0, gzip", "gzip", "gzipped"},
+		{"gzip, *;q=0", "gzip", "gzipped"},
+	}
+	for _, tc := range cases {
+		client.GET("/static/app.js").Header("Accept-Encoding", tc.accept).Do().
+			ExpectStatus(http.StatusOK).
+			ExpectHeader("Vary", "Accept-Encoding").
+			ExpectHeader("Content-Encoding", tc.encoding).
+			ExpectBody(tc.body)
+	}
+	// Content-Type 取自原文件名
+	res := client.GET("/static/app.js").Header("Accept-Encoding", "gzip").Do()
+	if ct := res.Header().Get("Content-Type"); !strings.Contains(ct, "javascript") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	// 不同编码的 ETag 不同
+	if res.Header().Get("ETag") == client.GET("/static/app.js").Do().Header().Get("ETag") {
+		t.Error("gzip and identity share an ETag")
+	}
+	client.GET("/static/only.css").Header("Accept-Encoding", "gzip, br").Do().
+		ExpectHeader("Vary", "Accept-Encoding").
+		ExpectHeader("Content-Encoding", "").
+		ExpectBody("css")
+}
+
+func TestStaticBrowse(t *testing.T) {
+	fsys := fstest.MapFS{
+		"docs/readme.txt":    {Data: []byte("readme")},
+		"docs/a b/c.txt":     {Data: []byte("c")},
+		"site/index.html":    {Data: []byte("index")},
+		"site/other.html":    {Data: []byte("other")},
+		"docs/<script>.html": {Data: []byte("x")},
+	}
+	r := gee.New()
+	r.StaticWithConfig("/browse", gee.StaticConfig{Root: fsys, Browse: true})
+	r.StaticWithConfig("/static", gee.StaticConfig{Root: fsys})
+	client := geetest.New(t, r)
+
+	client.GET("/browse/docs/").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectHeader("Content-Type", "text/html; charset=utf-8").
+		ExpectBodyContains(`<a href="readme.txt">readme.txt</a>`).
+		ExpectBodyContains(`<a href="a%20b/">a b/</a>`).
+		ExpectBodyContains(`&lt;script&gt;.html`)
+	client.GET("/browse/docs").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/browse/docs/")
+	client.GET("/browse/site/").Do().ExpectStatus(http.StatusOK).ExpectBody("index")
+
+	client.GET("/static/docs/").Do().ExpectStatus(http.StatusNotFound)
+	client.GET("/static/site/").Do().ExpectStatus(http.StatusOK).ExpectBody("index")
+	client.GET("/static/site/other.html/").Do().
+		ExpectStatus(http.StatusMovedPermanently).
+		ExpectHeader("Location", "/static/site/other.html")
+}
+
+func TestStaticTraversal(t *testing.T) {
+	fsys := fstest.MapFS{
+		"public/hello.txt": {Data: []byte("hello")},
+		"secret.txt":       {Data: []byte("secret")},
+	}
+	public, err := fs.Sub(fsys, "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gee.New()
+	r.RedirectCleanPath = false
+	r.StaticFS("/static", public)
+	client := geetest.New(t, r)
+
+	client.GET("/static/hello.txt").Do().ExpectStatus(http.StatusOK).ExpectBody("hello")
+	for _, p := range []string{
+		"/static/../secret.txt",
+		"/static/%2e%2e/secret.txt",
+		"/static/..%2fsecret.txt",
+		"/static/sub/../../secret.txt",
+		"/static/..%5csecret.txt",
+	} {
+		res := client.GET(p).Do()
+		if res.Code != http.StatusNotFound || res.Body.String() == "secret" {
+			t.Errorf("%s: status %d, body %q", p, res.Code, res.Body.String())
+		}
+	}
+}
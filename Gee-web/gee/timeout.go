@@ -0,0 +1,182 @@
+package gee
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig configures the Timeout middleware
+type TimeoutConfig struct {
+	// Timeout is the deadline given to the rest of the chain
+	Timeout time.Duration
+	// Handler writes the response when the deadline passes,
+	// the default answers 503 Service Unavailable
+	Handler HandlerFunc
+}
+
+// Timeout middleware cancels c.Req.Context() after d and answers 503 when the
+// chain is still running, it can be used per group or per route:
+//
+//	r.GET("/report", gee.Timeout(2*time.Second), report)
+func Timeout(d time.Duration) HandlerFunc {
+	return TimeoutWithConfig(TimeoutConfig{Timeout: d})
+}
+
+// TimeoutWithConfig returns a Timeout middleware with config
+func TimeoutWithConfig(config TimeoutConfig) HandlerFunc {
+	if config.Handler == nil {
+		config.Handler = func(c *Context) {
+			c.String(http.StatusServiceUnavailable, "503 SERVICE UNAVAILABLE: %s\n", c.Path)
+		}
+	}
+	return func(c *Context) {
+		req := c.Req
+		ctx, cancel := context.WithTimeout(req.Context(), config.Timeout)
+		defer cancel()
+
+		// 剩余的 handlers 在新的 goroutine 中执行，写入缓冲区，
+		// 超时之后的写入不会影响已经返回的响应
+		tw := &timeoutWriter{header: make(http.Header)}
+		// goroutine 使用自己的 Keys、Params 和 Errors，超时之后不再和外层共享
+		cc := *c
+		cc.Req = req.WithContext(ctx)
+		if req.Body != nil && req.Body != http.NoBody {
+			cc.Req.Body = &timeoutBody{ReadCloser: req.Body, ctx: ctx}
+		}
+		cc.writer = newResponseWriter(tw)
+		cc.Writer = cc.writer
+		cc.Keys = cloneMap(c.Keys)
+		cc.Params = cloneMap(c.Params)
+		cc.Errors = append(errorMsgs(nil), c.Errors...)
+
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- &timeoutPanic{value: p, stack: debug.Stack()}
+				}
+			}()
+			cc.Next()
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			c.Abort()
+			panic(p)
+		case <-done:
+			c.index = cc.index
+			c.Errors = cc.Errors
+			c.Params = cc.Params
+			c.Keys = cc.Keys
+			tw.flushTo(c)
+		case <-ctx.Done():
+			tw.timeout()
+			c.Abort()
+			c.Error(ctx.Err()).SetStatus(http.StatusServiceUnavailable)
+			config.Handler(c)
+		}
+	}
+}
+
+// timeoutWriter buffers the response of the handlers running under Timeout
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	buf      bytes.Buffer
+	code     int
+	timedOut bool
+}
+
+var _ http.ResponseWriter = (*timeoutWriter)(nil)
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(data []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(data)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
+
+// timeout discard the buffered response, later writes fail
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	tw.buf.Reset()
+}
+
+// flushTo copy the buffered response to the writer of c
+func (tw *timeoutWriter) flushTo(c *Context) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	dst := c.Writer.Header()
+	for k, v := range tw.header {
+		dst[k] = v
+	}
+	if tw.code == 0 {
+		return
+	}
+	c.Status(tw.code)
+	c.Writer.Write(tw.buf.Bytes())
+}
+
+// timeoutBody fails the reads of the request body once the deadline has passed,
+// the server may close the body as soon as the response is sent
+type timeoutBody struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	if b.ctx.Err() != nil {
+		return 0, http.ErrHandlerTimeout
+	}
+	return b.ReadCloser.Read(p)
+}
+
+// cloneMap returns a shallow copy of m, nil stays nil
+func cloneMap[V any](m map[string]V) map[string]V {
+	if m == nil {
+		return nil
+	}
+	clone := make(map[string]V, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+	return clone
+}
+
+// timeoutPanic carries a panic of the handler goroutine to the caller
+type timeoutPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *timeoutPanic) Error() string {
+	return fmt.Sprintf("%v\n\ngoroutine stack:\n%s", p.value, p.stack)
+}
@@ -0,0 +1,91 @@
+package gee_test
+
+import (
+	"errors"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	r := gee.New()
+	r.Use(gee.Recovery())
+	late := make(chan struct{})
+	r.GET("/fast", gee.Timeout(time.Second), func(c *gee.Context) {
+		c.SetHeader("X-Fast", "1")
+		c.String(http.StatusCreated, "fast")
+	})
+	r.GET("/slow", gee.Timeout(20*time.Millisecond), func(c *gee.Context) {
+		<-c.Req.Context().Done()
+		c.String(http.StatusOK, "too late")
+		close(late)
+	})
+	r.GET("/panic", gee.Timeout(time.Second), func(c *gee.Context) {
+		panic("boom")
+	})
+	custom := r.Group("/custom")
+	custom.Use(gee.TimeoutWithConfig(gee.TimeoutConfig{
+		Timeout: 10 * time.Millisecond,
+		Handler: func(c *gee.Context) {
+			c.JSON(http.StatusGatewayTimeout, gee.H{"error": "timeout"})
+		},
+	}))
+	custom.GET("/slow", func(c *gee.Context) {
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	client := geetest.New(t, r)
+	client.GET("/fast").Do().
+		ExpectStatus(http.StatusCreated).
+		ExpectHeader("X-Fast", "1").
+		ExpectBody("fast")
+	client.GET("/slow").Do().
+		ExpectStatus(http.StatusServiceUnavailable).
+		ExpectBody("503 SERVICE UNAVAILABLE: /slow\n")
+	<-late
+	client.GET("/custom/slow").Do().
+		ExpectStatus(http.StatusGatewayTimeout).
+		ExpectJSON("error", "timeout")
+	client.GET("/panic").Do().ExpectStatus(http.StatusInternalServerError)
+}
+
+func TestTimeoutLateHandler(t *testing.T) {
+	r := gee.New()
+	late := make(chan error)
+	var outer *gee.Context
+	r.Use(func(c *gee.Context) {
+		c.Set("user", "tom")
+		c.Next()
+		// 超时的 handler 仍在运行时，外层继续使用 Keys 和 Errors
+		for i := 0; i < 100; i++ {
+			c.Set("outer", i)
+			c.Error(errors.New("outer"))
+		}
+		outer = c
+	})
+	r.POST("/slow/:id", gee.Timeout(10*time.Millisecond), func(c *gee.Context) {
+		<-c.Req.Context().Done()
+		for i := 0; i < 100; i++ {
+			c.Set("late", i)
+			c.Params["id"] = "changed"
+			c.Error(errors.New("late"))
+		}
+		_, err := c.Req.Body.Read(make([]byte, 1))
+		late <- err
+	})
+
+	geetest.New(t, r).POST("/slow/1").Body(strings.NewReader("body")).Do().
+		ExpectStatus(http.StatusServiceUnavailable)
+	if err := <-late; err != http.ErrHandlerTimeout {
+		t.Errorf("late body read: err = %v, want %v", err, http.ErrHandlerTimeout)
+	}
+	if _, ok := outer.Get("late"); ok || outer.Param("id") != "1" {
+		t.Error("the timed out handler changed the outer context")
+	}
+	if v, _ := outer.Get("user"); v != "tom" {
+		t.Errorf("user = %v, want tom", v)
+	}
+}
@@ -0,0 +1,440 @@
+package gee
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spanKey = "gee/span"
+
+// W3C Trace Context headers
+const (
+	HeaderTraceParent = "traceparent"
+	HeaderTraceState  = "tracestate"
+)
+
+// ErrTraceParent is returned by ParseTraceParent for an invalid header
+var ErrTraceParent = errors.New("trace: invalid traceparent")
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// IsValid reports whether id is not all zero
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalJSON write the id as hex
+func (id TraceID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+// SpanID identifies a span of a trace
+type SpanID [8]byte
+
+// IsValid reports whether id is not all zero
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// MarshalJSON write the id as hex
+func (id SpanID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+
+// SpanContext is the part of a span propagated to other services
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+}
+
+// Sampled reports whether the sampled flag is set
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&0x01 != 0
+}
+
+// TraceParent format sc as a version 00 traceparent header
+func (sc SpanContext) TraceParent() string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceParent parse a traceparent header, the fields of future versions
+// after the flags are ignored as the specification asks
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrTraceParent
+	}
+	version, ok := decodeHex(s[:2])
+	if !ok || version[0] == 0xff || (version[0] == 0 && len(s) != 55) || (len(s) > 55 && s[55] != '-') {
+		return sc, ErrTraceParent
+	}
+	traceID, ok1 := decodeHex(s[3:35])
+	spanID, ok2 := decodeHex(s[36:52])
+	flags, ok3 := decodeHex(s[53:55])
+	if !ok1 || !ok2 || !ok3 {
+		return sc, ErrTraceParent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.TraceID.IsValid() || !sc.SpanID.IsValid() {
+		return SpanContext{}, ErrTraceParent
+	}
+	return sc, nil
+}
+
+// decodeHex decode lowercase hex only, as traceparent requires
+func decodeHex(s string) ([]byte, bool) {
+	if strings.ToLower(s) != s {
+		return nil, false
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// SpanData is the record of a finished span given to the Exporter
+type SpanData struct {
+	Name       string                 `json:"name"`
+	TraceID    TraceID                `json:"traceId"`
+	SpanID     SpanID                 `json:"spanId"`
+	ParentID   SpanID                 `json:"parentId"`
+	TraceState string                 `json:"traceState,omitempty"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+}
+
+// Span is an operation of a trace, the span of a request is started by Tracing
+// and available through c.Span()
+type Span struct {
+	mu       sync.Mutex
+	data     SpanData
+	flags    byte
+	exporter Exporter
+	ended    bool
+}
+
+// SpanContext returns the ids propagated to the services called by the span
+func (s *Span) SpanContext() SpanContext {
+	return SpanContext{
+		TraceID:    s.data.TraceID,
+		SpanID:     s.data.SpanID,
+		Flags:      s.flags,
+		TraceState: s.data.TraceState,
+	}
+}
+
+// SetName rename the span
+func (s *Span) SetName(name string) {
+	s.mu.Lock()
+	s.data.Name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute set an attribute of the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]interface{})
+	}
+	s.data.Attributes[key] = value
+}
+
+// SetError mark the span as failed
+func (s *Span) SetError(err error) {
+	s.mu.Lock()
+	s.data.Error = err.Error()
+	s.mu.Unlock()
+}
+
+// StartChild start a span of the same trace with s as parent
+func (s *Span) StartChild(name string) *Span {
+	sc := s.SpanContext()
+	return &Span{
+		data: SpanData{
+			Name:       name,
+			TraceID:    sc.TraceID,
+			SpanID:     newSpanID(),
+			ParentID:   sc.SpanID,
+			TraceState: sc.TraceState,
+			Start:      time.Now(),
+		},
+		flags:    sc.Flags,
+		exporter: s.exporter,
+	}
+}
+
+// End finish the span, sampled spans are exported once
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+	if s.exporter != nil && s.flags&0x01 != 0 {
+		if err := s.exporter.Export(data); err != nil {
+			warnPrint("export span %s: %v", data.Name, err)
+		}
+	}
+}
+
+// Exporter receives the finished spans
+type Exporter interface {
+	Export(span SpanData) error
+}
+
+// InMemoryExporter keeps the spans in memory, it's meant for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+// NewInMemoryExporter create an empty InMemoryExporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+// Export implements Exporter
+func (e *InMemoryExporter) Export(span SpanData) error {
+	e.mu.Lock()
+	e.spans = append(e.spans, span)
+	e.mu.Unlock()
+	return nil
+}
+
+// Spans returns the exported spans in order
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// Reset drop the exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	e.spans = nil
+	e.mu.Unlock()
+}
+
+// JSONExporter writes a json object per span and line
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONExporter create a JSONExporter writing to w
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+// Export implements Exporter
+func (e *JSONExporter) Export(span SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.enc.Encode(span)
+}
+
+// TracingConfig configures the Tracing middleware
+type TracingConfig struct {
+	// Exporter receives the sampled spans
+	Exporter Exporter
+	// Sampler decides whether a new trace is sampled, default all;
+	// the decision of an incoming traceparent is always kept
+	Sampler func(c *Context) bool
+	// OnStart is called after the span of the request is started
+	OnStart func(c *Context, span *Span)
+	// OnEnd is called before the span of the request ends
+	OnEnd func(c *Context, span *Span)
+}
+
+// Tracing middleware starts a span per request, exporting them to exporter
+func Tracing(exporter Exporter) HandlerFunc {
+	return TracingWithConfig(TracingConfig{Exporter: exporter})
+}
+
+// TracingWithConfig returns a Tracing middleware with config.
+// The span continues the trace of the traceparent and tracestate headers,
+// it's named after the route pattern and sent back in the traceparent header
+func TracingWithConfig(config TracingConfig) HandlerFunc {
+	return func(c *Context) {
+		parent, err := ParseTraceParent(c.Req.Header.Get(HeaderTraceParent))
+		var sc SpanContext
+		if err == nil {
+			sc = parent
+			sc.TraceState = parseTraceState(c.Req.Header.Values(HeaderTraceState))
+		} else {
+			sc.TraceID = newTraceID()
+			if config.Sampler == nil || config.Sampler(c) {
+				sc.Flags = 0x01
+			}
+		}
+		name := c.FullPath()
+		if name == "" {
+			name = unmatchedRoute
+		}
+		span := &Span{
+			data: SpanData{
+				Name:       name,
+				TraceID:    sc.TraceID,
+				SpanID:     newSpanID(),
+				ParentID:   parent.SpanID,
+				TraceState: sc.TraceState,
+				Start:      time.Now(),
+			},
+			flags:    sc.Flags,
+			exporter: config.Exporter,
+		}
+		span.SetAttribute("http.method", c.Method)
+		span.SetAttribute("http.route", c.FullPath())
+		span.SetAttribute("http.target", c.Req.URL.RequestURI())
+		c.Set(spanKey, span)
+		c.Req = c.Req.WithContext(ContextWithSpan(c.Req.Context(), span))
+		InjectTrace(c.Req.Context(), c.Writer.Header())
+		if config.OnStart != nil {
+			config.OnStart(c, span)
+		}
+
+		defer func() {
+			p := recover()
+			status := c.writer.status
+			if p != nil && !c.writer.Written() {
+				status = http.StatusInternalServerError
+			}
+			span.SetAttribute("http.status_code", status)
+			if p != nil {
+				span.SetError(errors.New("panic"))
+			} else if last := c.Errors.Last(); last != nil {
+				span.SetError(last)
+			} else if status >= http.StatusInternalServerError {
+				span.SetError(errors.New(http.StatusText(status)))
+			}
+			if config.OnEnd != nil {
+				config.OnEnd(c, span)
+			}
+			span.End()
+			if p != nil {
+				panic(p)
+			}
+		}()
+		c.Next()
+	}
+}
+
+// Span returns the span of the request started by Tracing, nil if none
+func (c *Context) Span() *Span {
+	if v, ok := c.Get(spanKey); ok {
+		span, _ := v.(*Span)
+		return span
+	}
+	return nil
+}
+
+type spanContextKey struct{}
+
+// ContextWithSpan returns a copy of ctx carrying span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the span carried by ctx, nil if none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+// InjectTrace set the traceparent and tracestate headers of the span carried by ctx
+func InjectTrace(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+	sc := span.SpanContext()
+	header.Set(HeaderTraceParent, sc.TraceParent())
+	if sc.TraceState != "" {
+		header.Set(HeaderTraceState, sc.TraceState)
+	} else {
+		header.Del(HeaderTraceState)
+	}
+}
+
+// TraceTransport is a http.RoundTripper propagating the span of the request context:
+//
+//	client := &http.Client{Transport: gee.TraceTransport{}}
+//	req, _ := http.NewRequestWithContext(c.Req.Context(), "GET", url, nil)
+type TraceTransport struct {
+	// Base default http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t TraceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if SpanFromContext(req.Context()) != nil {
+		// RoundTripper 不能修改原请求
+		req = req.Clone(req.Context())
+		InjectTrace(req.Context(), req.Header)
+	}
+	return base.RoundTrip(req)
+}
+
+// parseTraceState join the tracestate headers, a list over the limit of
+// 32 members is dropped rather than truncated
+func parseTraceState(values []string) string {
+	var members []string
+	for _, v := range values {
+		for _, m := range strings.Split(v, ",") {
+			if m = strings.TrimSpace(m); m != "" {
+				if !strings.Contains(m, "=") {
+					return ""
+				}
+				members = append(members, m)
+			}
+		}
+	}
+	if len(members) > 32 {
+		return ""
+	}
+	return strings.Join(members, ",")
+}
+
+func newTraceID() (id TraceID) {
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return id
+}
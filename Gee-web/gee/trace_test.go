@@ -0,0 +1,129 @@
+package gee_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	sc, err := gee.ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled() {
+		t.Errorf("parsed %+v", sc)
+	}
+	if got := sc.TraceParent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("TraceParent() = %q", got)
+	}
+	// 未来版本可以在末尾追加字段
+	if _, err := gee.ParseTraceParent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra"); err != nil {
+		t.Errorf("future version: %v", err)
+	}
+	for _, s := range []string{
+		"",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := gee.ParseTraceParent(s); err == nil {
+			t.Errorf("ParseTraceParent(%q) succeeds", s)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	exporter := gee.NewInMemoryExporter()
+	var upstream http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		upstream = req.Header.Clone()
+	}))
+	defer backend.Close()
+
+	r := gee.New()
+	r.Use(gee.Tracing(exporter))
+	r.GET("/user/:id", func(c *gee.Context) {
+		child := c.Span().StartChild("load user")
+		child.End()
+		client := &http.Client{Transport: gee.TraceTransport{}}
+		req, _ := http.NewRequestWithContext(c.Req.Context(), http.MethodGet, backend.URL, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			c.AbortWithError(http.StatusBadGateway, err)
+			return
+		}
+		res.Body.Close()
+		c.String(http.StatusOK, "ok")
+	})
+	client := geetest.New(t, r)
+
+	res := client.GET("/user/1").
+		Header("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01").
+		Header("tracestate", "congo=t61rcWkgMzE, rojo=00f067aa0ba902b7").
+		Do().ExpectStatus(http.StatusOK)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	child, span := spans[0], spans[1]
+	if span.Name != "/user/:id" || span.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+		span.ParentID.String() != "00f067aa0ba902b7" || span.TraceState != "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7" {
+		t.Errorf("request span %+v", span)
+	}
+	if span.Attributes["http.status_code"] != http.StatusOK || span.Attributes["http.target"] != "/user/1" {
+		t.Errorf("attributes %v", span.Attributes)
+	}
+	if child.Name != "load user" || child.ParentID != span.SpanID || child.TraceID != span.TraceID {
+		t.Errorf("child span %+v", child)
+	}
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanID.String() + "-01"
+	res.ExpectHeader("traceparent", want)
+	if upstream.Get("traceparent") != want || upstream.Get("tracestate") != span.TraceState {
+		t.Errorf("upstream headers %v", upstream)
+	}
+}
+
+func TestTracingSampler(t *testing.T) {
+	var buf bytes.Buffer
+	var ended []string
+	r := gee.New()
+	r.Use(gee.TracingWithConfig(gee.TracingConfig{
+		Exporter: gee.NewJSONExporter(&buf),
+		Sampler:  func(c *gee.Context) bool { return c.Query("sample") != "" },
+		OnEnd: func(c *gee.Context, span *gee.Span) {
+			ended = append(ended, span.SpanContext().TraceID.String())
+		},
+	}))
+	r.GET("/fail", func(c *gee.Context) {
+		c.AbortWithError(http.StatusInternalServerError, http.ErrHandlerTimeout)
+	})
+	client := geetest.New(t, r)
+
+	client.GET("/fail").Do()
+	client.GET("/fail").Query("sample", "1").Do()
+	client.GET("/missing").Header("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00").Do()
+	if len(ended) != 3 {
+		t.Errorf("OnEnd called %d times", len(ended))
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("exported %d spans, want only the sampled one: %q", len(lines), buf.String())
+	}
+	var span map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &span); err != nil {
+		t.Fatal(err)
+	}
+	if span["name"] != "/fail" || span["error"] != http.ErrHandlerTimeout.Error() || span["traceId"] != ended[1] {
+		t.Errorf("exported %v", span)
+	}
+}
@@ -4,15 +4,21 @@ import "strings"
 
 type node struct {
 	pattern  string  //待匹配路由  如： /p/:lang
+	route    string  // registered pattern, /list/:page? for the node of /list
 	part     string  //路由中的一部分  如：  :lang
 	children []*node //子节点
 	isWild   bool    //是否精确匹配  part中含有 : 或 * 为true
+	// constraint of a param part like :id<int>, nil accepts every value
+	constraint func(string) bool
+	// segment of a part mixing static text and params like :name.:ext
+	segment *segment
 }
 
-// matchChild insert时 查找是否有part的子节点
+// matchChild insert时 查找是否有part的子节点，只做精确匹配，
+// 否则 /*filepath 之后注册的 /api/users 会被插入到通配节点下
 func (n *node) matchChild(part string) *node {
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
+		if child.part == part {
 			return child
 		}
 	}
@@ -20,29 +26,83 @@ func (n *node) matchChild(part string) *node {
 }
 
 // matchChildren search时 查找children中所有等于part的节点
+// 空的part表示末尾的斜杠，只有 * 能匹配
 func (n *node) matchChildren(part string) []*node {
 	nodes := make([]*node, 0)
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
+		if child.part == part || child.matchWild(part) {
 			nodes = append(nodes, child)
 		}
 	}
 	return nodes
 }
 
-// insert 插入节点
-func (n *node) insert(pattern string, parts []string, height int) {
+// matchWild reports whether the wild node n accepts part
+func (n *node) matchWild(part string) bool {
+	if !n.isWild {
+		return false
+	}
+	if n.constraint != nil && !n.constraint(part) {
+		return false
+	}
+	if n.segment != nil {
+		return n.segment.re.MatchString(part)
+	}
+	return part != "" || n.part[0] == '*'
+}
+
+// priority 匹配优先级：静态节点 > 混合节点(如 v:version) > 带约束的:参数 > :参数 > *通配
+// 同一优先级按注册顺序匹配
+func (n *node) priority() int {
+	switch {
+	case strings.HasPrefix(n.part, "*"):
+		return 4
+	case n.segment != nil:
+		return 1
+	case n.isWild && n.constraint == nil:
+		return 3
+	case n.isWild:
+		return 2
+	}
+	return 0
+}
+
+// addChild keep children ordered by priority, so search always
+// tries static parts before params and catch-all
+func (n *node) addChild(child *node) {
+	i := len(n.children)
+	for i > 0 && n.children[i-1].priority() > child.priority() {
+		i--
+	}
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+}
+
+// insert 插入节点，返回 pattern 的叶子节点
+func (n *node) insert(pattern string, parts []string, height int) *node {
 	if len(parts) == height {
 		n.pattern = pattern
-		return
+		return n
 	}
 	part := parts[height]
 	child := n.matchChild(part)
 	if child == nil {
-		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
-		n.children = append(n.children, child)
+		child = &node{part: part, isWild: strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*")}
+		if child.segment = parseSegment(part); child.segment != nil {
+			child.isWild = true
+		} else if strings.HasPrefix(part, ":") {
+			_, constraint := splitParam(part)
+			child.constraint = compileConstraint(constraint)
+		}
+		n.addChild(child)
 	}
-	child.insert(pattern, parts, height+1)
+	return child.insert(pattern, parts, height+1)
+}
+
+// isCatchAll reports whether n ends a pattern with a catch-all part like *filepath
+func (n *node) isCatchAll() bool {
+	return strings.HasPrefix(n.part, "*")
 }
 
 // search 查找pattern
@@ -63,3 +123,23 @@ func (n *node) search(parts []string, height int) *node {
 	}
 	return nil
 }
+
+// searchFold is search with static parts compared case-insensitively
+func (n *node) searchFold(parts []string, height int) *node {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil
+		}
+		return n
+	}
+	part := parts[height]
+	for _, child := range n.children {
+		if !strings.EqualFold(child.part, part) && !child.matchWild(part) {
+			continue
+		}
+		if result := child.searchFold(parts, height+1); result != nil {
+			return result
+		}
+	}
+	return nil
+}
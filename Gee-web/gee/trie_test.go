@@ -0,0 +1,81 @@
+package gee
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		want    []string
+	}{
+		{"/", []string{""}},
+		{"/p/:name", []string{"p", ":name"}},
+		{"/p/:name/", []string{"p", ":name", ""}},
+		{"/p/*", []string{"p", "*"}},
+		{"/p/*name/*", []string{"p", "*name"}},
+		{"/p//go", []string{"p", "go"}},
+	}
+	for _, tc := range cases {
+		if got := parsePattern(tc.pattern); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parsePattern(%q) = %q, want %q", tc.pattern, got, tc.want)
+		}
+	}
+}
+
+func TestTrieSearch(t *testing.T) {
+	root := &node{}
+	for _, pattern := range []string{"/*filepath", "/p/:lang/doc", "/p/go/doc", "/p/:lang", "/hello/"} {
+		root.insert(pattern, parsePattern(pattern), 0)
+	}
+	cases := []struct {
+		path string
+		want string
+	}{
+		{"/p/go/doc", "/p/go/doc"},
+		{"/p/c/doc", "/p/:lang/doc"},
+		{"/p/c", "/p/:lang"},
+		{"/hello/", "/hello/"},
+		{"/hello", "/*filepath"},
+		{"/p/c/doc/x", "/*filepath"},
+		{"/", "/*filepath"},
+	}
+	for _, tc := range cases {
+		n := root.search(parsePattern(tc.path), 0)
+		if n == nil || n.pattern != tc.want {
+			t.Errorf("search(%q) = %v, want %q", tc.path, n, tc.want)
+		}
+	}
+}
+
+func TestTrieStaticBeforeWild(t *testing.T) {
+	root := &node{}
+	root.insert("/:name", parsePattern("/:name"), 0)
+	root.insert("/api", parsePattern("/api"), 0)
+	if len(root.children) != 2 || root.children[0].part != "api" {
+		t.Fatalf("static child should come first, got %v", root.children)
+	}
+	if n := root.searchFold(parsePattern("/API"), 0); n == nil || n.pattern != "/api" {
+		t.Errorf("searchFold(/API) = %v, want /api", n)
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	cases := map[string]string{
+		"":             "/",
+		"/":            "/",
+		"/a//b":        "/a/b",
+		"/a/./b/":      "/a/b/",
+		"/a/../b":      "/b",
+		"/../a":        "/a",
+		"a/b":          "/a/b",
+		"/a/b/../../":  "/",
+		"/a/b/c/../..": "/a",
+	}
+	for p, want := range cases {
+		if got := cleanPath(p); got != want {
+			t.Errorf("cleanPath(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
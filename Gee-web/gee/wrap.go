@@ -0,0 +1,103 @@
+package gee
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mountMethods are the methods routed to a mounted handler
+var mountMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// WrapF adapt a http.HandlerFunc to HandlerFunc
+func WrapF(f http.HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		f(c.Writer, c.Req)
+	}
+}
+
+// WrapH adapt a http.Handler to HandlerFunc
+func WrapH(h http.Handler) HandlerFunc {
+	return func(c *Context) {
+		h.ServeHTTP(c.Writer, c.Req)
+	}
+}
+
+// WrapMiddleware adapt a net/http middleware to a gee middleware.
+// The rest of the chain runs when the middleware calls its next handler,
+// with the request and writer it passes on; a middleware that doesn't call
+// next aborts the chain
+func WrapMiddleware(middleware func(http.Handler) http.Handler) HandlerFunc {
+	return func(c *Context) {
+		called := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+			writer, origWriter, origin := c.writer, c.Writer, c.Req
+			// 中间件替换了 writer 时重新包装，Written 和状态码跟随新的 writer
+			if w != c.Writer {
+				c.writer = newResponseWriter(w)
+			}
+			c.Writer, c.Req = c.writer, req
+			defer func() {
+				c.writer, c.Writer, c.Req = writer, origWriter, origin
+			}()
+			c.Next()
+		})
+		middleware(next).ServeHTTP(c.Writer, c.Req)
+		if !called {
+			c.Abort()
+		}
+	}
+}
+
+// Handle register a handler for method and pattern
+func (g *RouterGroup) Handle(method string, pattern string, handlers ...HandlerFunc) *Route {
+	return g.addRouter(method, pattern, handlers...)
+}
+
+// Mount serve every request under prefix with h, the prefix is stripped from
+// the request path, so h may be a sub application or another Engine
+func (g *RouterGroup) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	strip := g.prefix + prefix
+	handler := func(c *Context) {
+		req := c.Req.Clone(c.Req.Context())
+		req.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(c.Req.URL.Path, strip), "/")
+		// RawPath 中的前缀是转义过的，如 /a%20b 对应 /a b
+		req.URL.RawPath = ""
+		if rest, ok := stripRawPrefix(c.Req.URL.RawPath, strip); ok {
+			req.URL.RawPath = "/" + strings.TrimPrefix(rest, "/")
+		}
+		h.ServeHTTP(c.Writer, req)
+	}
+	for _, method := range mountMethods {
+		if prefix != "" {
+			g.addRouter(method, prefix, handler).Meta.Hidden = true
+		}
+		g.addRouter(method, prefix+"/*path", handler).Meta.Hidden = true
+	}
+}
+
+// stripRawPrefix strip the escaped form of the decoded prefix from rawPath,
+// it's false when rawPath is empty or doesn't start with prefix
+func stripRawPrefix(rawPath, prefix string) (string, bool) {
+	if rawPath == "" {
+		return "", false
+	}
+	for i := 0; i <= len(rawPath); i++ {
+		if i < len(rawPath) && rawPath[i] != '/' {
+			continue
+		}
+		decoded, err := url.PathUnescape(rawPath[:i])
+		if err != nil || len(decoded) > len(prefix) {
+			return "", false
+		}
+		if decoded == prefix {
+			return rawPath[i:], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,121 @@
+package gee_test
+
+import (
+	"context"
+	"gee"
+	"gee/geetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ctxKey struct{}
+
+func TestWrapAndMount(t *testing.T) {
+	sub := gee.New()
+	sub.GET("/users/:id", func(c *gee.Context) {
+		c.String(http.StatusOK, "sub %s %s", c.Path, c.Param("id"))
+	})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong " + r.URL.Path))
+	})
+
+	r := gee.New()
+	r.Mount("/sub", sub)
+	r.Group("/legacy").Mount("/", mux)
+	r.GET("/f", gee.WrapF(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	client := geetest.New(t, r)
+	client.GET("/sub/users/7").Do().ExpectStatus(http.StatusOK).ExpectBody("sub /users/7 7")
+	client.POST("/sub/users/7").Do().ExpectStatus(http.StatusMethodNotAllowed)
+	client.GET("/legacy/ping").Do().ExpectStatus(http.StatusOK).ExpectBody("pong /ping")
+	client.GET("/f").Do().ExpectStatus(http.StatusAccepted)
+}
+
+func TestMountRawPath(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.URL.RawPath))
+	})
+	r := gee.New()
+	r.Mount("/a b", echo)
+	r.Mount("/files", echo)
+	client := geetest.New(t, r)
+
+	// 前缀在 RawPath 中是转义过的
+	client.GET("/a%20b/x%2Fy").Do().ExpectStatus(http.StatusOK).ExpectBody("/x/y /x%2Fy")
+	client.GET("/files/x%2Fy").Do().ExpectStatus(http.StatusOK).ExpectBody("/x/y /x%2Fy")
+	client.GET("/a%20b/plain").Do().ExpectStatus(http.StatusOK).ExpectBody("/plain ")
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	withValue := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Before", "1")
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ctxKey{}, "v")))
+		})
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("ok") == "" {
+				http.Error(w, "denied", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	r := gee.New()
+	var after string
+	r.Use(gee.WrapMiddleware(withValue), gee.WrapMiddleware(deny))
+	r.Use(func(c *gee.Context) {
+		c.Next()
+		after = "done"
+	})
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusOK, "%v", c.Req.Context().Value(ctxKey{}))
+	})
+	client := geetest.New(t, r)
+	client.GET("/").Do().ExpectStatus(http.StatusForbidden).ExpectHeader("X-Before", "1")
+	if after != "" {
+		t.Error("chain should be aborted")
+	}
+	client.GET("/").Query("ok", "1").Do().ExpectStatus(http.StatusOK).ExpectBody("v")
+	if after != "done" {
+		t.Error("chain should run after next")
+	}
+}
+
+func TestWrapMiddlewareWriter(t *testing.T) {
+	// buffer 用自己的 writer 缓存响应，next 返回后才写给客户端
+	buffer := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rec := httptest.NewRecorder()
+			next.ServeHTTP(rec, r)
+			for key, values := range rec.Header() {
+				w.Header()[key] = values
+			}
+			w.Header().Set("X-Buffered", "1")
+			w.WriteHeader(rec.Code)
+			w.Write(rec.Body.Bytes())
+		})
+	}
+	r := gee.New()
+	var written bool
+	var status int
+	r.Use(gee.WrapMiddleware(buffer))
+	r.Use(func(c *gee.Context) {
+		c.Next()
+		written, status = c.Written(), c.StatusCode
+	})
+	r.GET("/", func(c *gee.Context) {
+		c.String(http.StatusCreated, "created")
+	})
+	geetest.New(t, r).GET("/").Do().
+		ExpectStatus(http.StatusCreated).
+		ExpectHeader("X-Buffered", "1").
+		ExpectBody("created")
+	if !written || status != http.StatusCreated {
+		t.Errorf("Written() = %v, StatusCode = %d inside the wrapped middleware", written, status)
+	}
+}
@@ -10,6 +10,9 @@ func main() {
 
 	r := gee.Default()
 	r.Static("/assets", "./static")
+	r.NoRoute(func(c *gee.Context) {
+		c.JSON(http.StatusNotFound, gee.H{"code": http.StatusNotFound, "path": c.Path})
+	})
 
 	r.GET("/", func(c *gee.Context) {
 		c.HTML(http.StatusOK, "css.html", nil)